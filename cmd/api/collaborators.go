@@ -0,0 +1,170 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"eatinn.dcashman.net/internal/data"
+	"eatinn.dcashman.net/internal/validator"
+)
+
+// The collaboratorRecipe() helper reads the recipe ID from the URL and fetches the
+// recipe, checking that the authenticated user is either its creator or one of its
+// collaborators. Recipes the user can't see at all are reported as missing. If the
+// check fails an error response is sent and nil is returned.
+func (app *application) collaboratorRecipe(w http.ResponseWriter, r *http.Request) *data.Recipe {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return nil
+	}
+
+	recipe, err := app.models.Recipes.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return nil
+	}
+
+	user := app.contextGetUser(r)
+	switch {
+	case recipe.UserID == user.ID || recipe.CollaboratorAccess(user) != "":
+		return recipe
+	case recipe.VisibleTo(user):
+		app.notPermittedResponse(w, r)
+	default:
+		app.notFoundResponse(w, r)
+	}
+
+	return nil
+}
+
+func (app *application) listCollaboratorsHandler(w http.ResponseWriter, r *http.Request) {
+	recipe := app.collaboratorRecipe(w, r)
+	if recipe == nil {
+		return
+	}
+
+	collaborators, err := app.models.Collaborators.GetAllForRecipe(recipe.ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"collaborators": collaborators}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// The addCollaboratorHandler() grants a user, identified by their email address, read or
+// edit access to the recipe. Adding an existing collaborator changes their access level.
+// Email addresses which don't belong to an activated user are silently ignored.
+func (app *application) addCollaboratorHandler(w http.ResponseWriter, r *http.Request) {
+	recipe := app.collaboratorRecipe(w, r)
+	if recipe == nil {
+		return
+	}
+
+	user := app.contextGetUser(r)
+	if recipe.UserID != user.ID {
+		app.notPermittedResponse(w, r)
+		return
+	}
+
+	var input struct {
+		Email  string `json:"email"`
+		Access string `json:"access"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	collaborator := &data.Collaborator{
+		RecipeID:  recipe.ID,
+		Access:    input.Access,
+		GrantedBy: user.ID,
+	}
+
+	v := validator.New()
+	data.ValidateEmail(v, input.Email)
+	data.ValidateCollaborator(v, collaborator)
+	v.Check(!strings.EqualFold(input.Email, user.Email), "email", "must not be the recipe's creator")
+
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	grantee, err := app.models.Users.GetByEmail(input.Email)
+	switch {
+	case err == nil && grantee.Activated:
+		collaborator.UserID = grantee.ID
+		collaborator.Name = grantee.Name
+		collaborator.Email = grantee.Email
+
+		err = app.models.Collaborators.Upsert(collaborator)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+	case err != nil && !errors.Is(err, data.ErrRecordNotFound):
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	// The response is the same whether or not the email address belongs to an
+	// activated user, so that the endpoint can't be used to find out who has an
+	// account.
+	env := envelope{"message": "if the email address belongs to an activated user, they now have access to the recipe"}
+
+	err = app.writeJSON(w, http.StatusAccepted, env, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// The revokeCollaboratorHandler() removes a collaborator. The recipe's creator can
+// remove anyone, and collaborators can remove themselves.
+func (app *application) revokeCollaboratorHandler(w http.ResponseWriter, r *http.Request) {
+	recipe := app.collaboratorRecipe(w, r)
+	if recipe == nil {
+		return
+	}
+
+	userID, err := app.readNamedIDParam(r, "user_id")
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	user := app.contextGetUser(r)
+	if recipe.UserID != user.ID && userID != user.ID {
+		app.notPermittedResponse(w, r)
+		return
+	}
+
+	err = app.models.Collaborators.Delete(recipe.ID, userID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"message": "collaborator successfully removed"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
@@ -0,0 +1,276 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"maps"
+	"net/url"
+	"os"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"eatinn.dcashman.net/internal/validator"
+)
+
+// Configuration sources, in increasing order of precedence.
+const (
+	configSourceDefault = "default"
+	configSourceFile    = "file"
+	configSourceEnv     = "env"
+	configSourceFlag    = "flag"
+)
+
+// configEnvPrefix is prepended to the upper-cased flag name to form the environment
+// variable for each setting, so that -db-dsn is read from EATINN_DB_DSN.
+const configEnvPrefix = "EATINN_"
+
+// redactedValue replaces secrets in the configuration returned by the API. It matches
+// the placeholder used by url.URL.Redacted().
+const redactedValue = "xxxxx"
+
+// dsnPasswordRX matches the password in a key/value style PostgreSQL DSN. Values may be
+// single-quoted, in which case they can contain spaces and backslash-escaped quotes.
+var dsnPasswordRX = regexp.MustCompile(`\bpassword\s*=\s*(?:'(?:[^'\\]|\\.)*(?:'|$)|\S+)`)
+
+// The loadConfig() function layers the configuration file and environment variables
+// underneath any flags that were explicitly passed on the command line, so that the
+// overall precedence is defaults < config file < env vars < flags. It must be called
+// after fs has been parsed. The file is a JSON object keyed by flag name, e.g.
+// {"port": 4000, "cors-trusted-origins": ["http://localhost:5173"]}. The source of each
+// setting is recorded in cfg.sources.
+func loadConfig(fs *flag.FlagSet, cfg *config) error {
+	cfg.sources = make(map[string]string)
+
+	fs.VisitAll(func(f *flag.Flag) {
+		cfg.sources[f.Name] = configSourceDefault
+	})
+	fs.Visit(func(f *flag.Flag) {
+		cfg.sources[f.Name] = configSourceFlag
+	})
+
+	// The config file location can itself come from the environment, but a flag wins.
+	if cfg.sources["config"] != configSourceFlag {
+		if path, ok := os.LookupEnv(configEnvName("config")); ok {
+			cfg.file = path
+			cfg.sources["config"] = configSourceEnv
+		}
+	}
+
+	if cfg.file != "" {
+		values, err := readConfigFile(cfg.file)
+		if err != nil {
+			return err
+		}
+
+		for _, name := range slices.Sorted(maps.Keys(values)) {
+			if name == "config" || fs.Lookup(name) == nil {
+				return fmt.Errorf("config file %s: unknown setting %q", cfg.file, name)
+			}
+
+			if cfg.sources[name] == configSourceFlag {
+				continue
+			}
+
+			err := fs.Set(name, values[name])
+			if err != nil {
+				return fmt.Errorf("config file %s: invalid value for %q: %w", cfg.file, name, err)
+			}
+			cfg.sources[name] = configSourceFile
+		}
+	}
+
+	var err error
+	fs.VisitAll(func(f *flag.Flag) {
+		if err != nil || f.Name == "config" || cfg.sources[f.Name] == configSourceFlag {
+			return
+		}
+
+		value, ok := os.LookupEnv(configEnvName(f.Name))
+		if !ok {
+			return
+		}
+
+		if setErr := fs.Set(f.Name, value); setErr != nil {
+			err = fmt.Errorf("environment variable %s: %w", configEnvName(f.Name), setErr)
+			return
+		}
+		cfg.sources[f.Name] = configSourceEnv
+	})
+
+	return err
+}
+
+// The configEnvName() helper returns the environment variable for a flag name.
+func configEnvName(name string) string {
+	return configEnvPrefix + strings.ToUpper(strings.ReplaceAll(name, "-", "_"))
+}
+
+// The readConfigFile() helper decodes a JSON config file into flag-compatible string
+// values. Lists are joined with spaces, matching the space separated list flags.
+func readConfigFile(path string) (map[string]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var raw map[string]any
+
+	dec := json.NewDecoder(f)
+	dec.UseNumber()
+
+	err = dec.Decode(&raw)
+	if err != nil {
+		return nil, fmt.Errorf("config file %s: %w", path, err)
+	}
+
+	values := make(map[string]string, len(raw))
+
+	for name, value := range raw {
+		s, err := configValueString(value)
+		if err != nil {
+			return nil, fmt.Errorf("config file %s: invalid value for %q: %w", path, name, err)
+		}
+		values[name] = s
+	}
+
+	return values, nil
+}
+
+func configValueString(value any) (string, error) {
+	switch v := value.(type) {
+	case string:
+		return v, nil
+	case json.Number:
+		return v.String(), nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	case []any:
+		items := make([]string, len(v))
+		for i := range v {
+			s, ok := v[i].(string)
+			if !ok {
+				return "", errors.New("list items must be strings")
+			}
+			items[i] = s
+		}
+		return strings.Join(items, " "), nil
+	default:
+		return "", errors.New("must be a string, number, boolean or list of strings")
+	}
+}
+
+// The validateConfig() function checks the effective configuration at startup,
+// returning a single error describing every problem found.
+func validateConfig(cfg config) error {
+	v := validator.New()
+
+	v.Check(cfg.port > 0 && cfg.port <= 65535, "port", "must be between 1 and 65535")
+	v.Check(validator.PermittedValue(cfg.env, "development", "staging", "production"), "env", "must be one of development, staging or production")
+
+	v.Check(cfg.db.dsn != "", "db-dsn", "must be provided")
+	v.Check(cfg.db.maxOpenConns >= 0, "db-max-open-conns", "must not be negative")
+	v.Check(cfg.db.maxIdleConns >= 0, "db-max-idle-conns", "must not be negative")
+	v.Check(cfg.db.maxIdleTime >= 0, "db-max-idle-time", "must not be negative")
+
+	if cfg.limiter.enabled {
+		v.Check(cfg.limiter.rps > 0, "limiter-rps", "must be greater than zero")
+		v.Check(cfg.limiter.burst > 0, "limiter-burst", "must be greater than zero")
+	}
+
+	v.Check(cfg.quota.maxRecipes >= 0, "quota-max-recipes", "must not be negative")
+	v.Check(cfg.quota.maxImageBytes >= 0, "quota-max-image-bytes", "must not be negative")
+
+	v.Check(cfg.smtp.host != "", "smtp-host", "must be provided")
+	v.Check(cfg.smtp.port > 0 && cfg.smtp.port <= 65535, "smtp-port", "must be between 1 and 65535")
+	v.Check(cfg.smtp.sender != "", "smtp-sender", "must be provided")
+
+	v.Check((cfg.tls.certFile == "") == (cfg.tls.keyFile == ""), "tls-cert-file", "must be provided together with -tls-key-file")
+	v.Check(cfg.tls.certFile == "" || len(cfg.tls.autocertDomains) == 0, "tls-autocert-domains", "cannot be combined with -tls-cert-file and -tls-key-file")
+	// The autocert manager only answers the TLS-ALPN-01 challenge, which Let's Encrypt
+	// always sends to port 443.
+	v.Check(cfg.port == 443 || len(cfg.tls.autocertDomains) == 0, "tls-autocert-domains", "requires -port 443")
+
+	v.Check(cfg.scheduler.publishInterval > 0, "publish-interval", "must be greater than zero")
+
+	if v.Valid() {
+		return nil
+	}
+
+	problems := make([]string, 0, len(v.Errors))
+	for _, name := range slices.Sorted(maps.Keys(v.Errors)) {
+		problems = append(problems, fmt.Sprintf("-%s %s", name, v.Errors[name]))
+	}
+
+	return fmt.Errorf("invalid configuration: %s", strings.Join(problems, "; "))
+}
+
+// The redacted() method returns the effective configuration in a form suitable for
+// returning to administrators, with passwords and other secrets removed.
+func (cfg config) redacted() map[string]any {
+	return map[string]any{
+		"file": cfg.file,
+		"port": cfg.port,
+		"env":  cfg.env,
+		"db": map[string]any{
+			"dsn":            redactDSN(cfg.db.dsn),
+			"max_open_conns": cfg.db.maxOpenConns,
+			"max_idle_conns": cfg.db.maxIdleConns,
+			"max_idle_time":  cfg.db.maxIdleTime.String(),
+		},
+		"limiter": map[string]any{
+			"rps":     cfg.limiter.rps,
+			"burst":   cfg.limiter.burst,
+			"enabled": cfg.limiter.enabled,
+		},
+		"quota": map[string]any{
+			"max_recipes":     cfg.quota.maxRecipes,
+			"max_image_bytes": cfg.quota.maxImageBytes,
+		},
+		"smtp": map[string]any{
+			"host":     cfg.smtp.host,
+			"port":     cfg.smtp.port,
+			"username": cfg.smtp.username,
+			"password": redactedValue,
+			"sender":   cfg.smtp.sender,
+		},
+		"cors": map[string]any{
+			"trusted_origins": cfg.cors.trustedOrigins,
+		},
+		"tls": map[string]any{
+			"cert_file":          cfg.tls.certFile,
+			"key_file":           cfg.tls.keyFile,
+			"autocert_domains":   cfg.tls.autocertDomains,
+			"autocert_cache_dir": cfg.tls.autocertCacheDir,
+		},
+		"scheduler": map[string]any{
+			"publish_interval": cfg.scheduler.publishInterval.String(),
+		},
+		"grocery": map[string]any{
+			"catalogs":       cfg.grocery.catalogs,
+			"shopify_stores": cfg.grocery.shopifyStores,
+		},
+	}
+}
+
+// The redactDSN() helper masks the password in either a URL or a key/value style
+// PostgreSQL DSN.
+func redactDSN(dsn string) string {
+	u, err := url.Parse(dsn)
+	if err == nil && u.Scheme != "" {
+		// The password may also be supplied as a query parameter.
+		qs := u.Query()
+		if qs.Has("password") {
+			qs.Set("password", redactedValue)
+			u.RawQuery = qs.Encode()
+		}
+
+		return u.Redacted()
+	}
+
+	return dsnPasswordRX.ReplaceAllString(dsn, "password="+redactedValue)
+}
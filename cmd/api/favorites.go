@@ -0,0 +1,73 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+
+	"eatinn.dcashman.net/internal/data"
+)
+
+// The favoriteRecipeHandler() adds a recipe the user can see to their favorites, so
+// that it is included in scope=mine searches. Favoriting is idempotent.
+func (app *application) favoriteRecipeHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	recipe, err := app.models.Recipes.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	user := app.contextGetUser(r)
+	if !recipe.VisibleTo(user) {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	err = app.models.Favorites.Insert(user.ID, recipe.ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"message": "recipe successfully added to favorites"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// The unfavoriteRecipeHandler() removes a recipe from the user's favorites. It doesn't
+// check that the recipe is still visible, so that favorites of recipes which have since
+// been made private can be cleared up.
+func (app *application) unfavoriteRecipeHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	err = app.models.Favorites.Delete(app.contextGetUser(r).ID, id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"message": "recipe successfully removed from favorites"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
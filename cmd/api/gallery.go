@@ -0,0 +1,232 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"eatinn.dcashman.net/internal/data"
+	"eatinn.dcashman.net/internal/validator"
+)
+
+// The editableRecipe() helper reads the recipe ID from the URL and fetches the recipe,
+// checking that the authenticated user is allowed to modify it (as its creator or an
+// edit collaborator). If not, an appropriate error response is sent and nil is returned.
+func (app *application) editableRecipe(w http.ResponseWriter, r *http.Request) *data.Recipe {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return nil
+	}
+
+	recipe, err := app.models.Recipes.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return nil
+	}
+
+	if !recipe.EditableBy(app.contextGetUser(r)) {
+		app.notPermittedResponse(w, r)
+		return nil
+	}
+
+	return recipe
+}
+
+func (app *application) addGalleryImageHandler(w http.ResponseWriter, r *http.Request) {
+	recipe := app.editableRecipe(w, r)
+	if recipe == nil {
+		return
+	}
+
+	var input struct {
+		URL     string `json:"url"`
+		Caption string `json:"caption"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	image := &data.GalleryImage{
+		RecipeID: recipe.ID,
+		URL:      input.URL,
+		Caption:  input.Caption,
+	}
+
+	v := validator.New()
+	data.ValidateGalleryImage(v, image)
+	v.Check(len(recipe.Gallery) < data.MaxGalleryImages, "gallery", fmt.Sprintf("must not contain more than %d images", data.MaxGalleryImages))
+
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.Gallery.Insert(image)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	headers := make(http.Header)
+	headers.Set("Location", fmt.Sprintf("/v1/recipes/%d/gallery/%d", recipe.ID, image.ID))
+
+	err = app.writeJSON(w, http.StatusCreated, envelope{"image": image}, headers)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) updateGalleryImageHandler(w http.ResponseWriter, r *http.Request) {
+	recipe := app.editableRecipe(w, r)
+	if recipe == nil {
+		return
+	}
+
+	imageID, err := app.readNamedIDParam(r, "image_id")
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	image, err := app.models.Gallery.Get(recipe.ID, imageID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	var input struct {
+		Caption *string `json:"caption"`
+	}
+
+	err = app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if input.Caption != nil {
+		image.Caption = *input.Caption
+	}
+
+	v := validator.New()
+	if data.ValidateGalleryImage(v, image); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.Gallery.UpdateCaption(image)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"image": image}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) deleteGalleryImageHandler(w http.ResponseWriter, r *http.Request) {
+	recipe := app.editableRecipe(w, r)
+	if recipe == nil {
+		return
+	}
+
+	imageID, err := app.readNamedIDParam(r, "image_id")
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	err = app.models.Gallery.Delete(recipe.ID, imageID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"message": "gallery image successfully deleted"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) reorderGalleryHandler(w http.ResponseWriter, r *http.Request) {
+	recipe := app.editableRecipe(w, r)
+	if recipe == nil {
+		return
+	}
+
+	var input struct {
+		ImageIDs []int64 `json:"image_ids"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	// The new order must be a permutation of the current gallery.
+	current := make(map[int64]bool, len(recipe.Gallery))
+	for _, image := range recipe.Gallery {
+		current[image.ID] = true
+	}
+
+	v := validator.New()
+	v.Check(validator.Unique(input.ImageIDs), "image_ids", "must not contain duplicate values")
+	v.Check(len(input.ImageIDs) == len(recipe.Gallery), "image_ids", "must contain every image in the gallery")
+	for _, id := range input.ImageIDs {
+		v.Check(current[id], "image_ids", fmt.Sprintf("image %d is not in the gallery", id))
+	}
+
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.Gallery.Reorder(recipe.ID, input.ImageIDs)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrEditConflict):
+			app.editConflictResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	gallery, err := app.models.Gallery.GetAllForRecipe(recipe.ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"gallery": gallery}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
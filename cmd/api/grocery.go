@@ -0,0 +1,186 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"eatinn.dcashman.net/internal/grocery"
+	"eatinn.dcashman.net/internal/validator"
+)
+
+// groceryMatchLimit is the number of product suggestions returned for each ingredient.
+const groceryMatchLimit = 5
+
+// maxCartItems is the largest number of different products which can be exported in a
+// single cart.
+const maxCartItems = 100
+
+// The groceryProvider() helper looks up the named provider, recording a validation
+// error if there is no such provider.
+func (app *application) groceryProvider(v *validator.Validator, name string) grocery.Provider {
+	provider, ok := app.grocery.Get(name)
+	if !ok {
+		names := app.grocery.Names()
+		if len(names) == 0 {
+			v.AddError("provider", "no grocery providers are configured")
+		} else {
+			v.AddError("provider", fmt.Sprintf("must be one of %s", strings.Join(names, ", ")))
+		}
+	}
+
+	return provider
+}
+
+func (app *application) listGroceryProvidersHandler(w http.ResponseWriter, r *http.Request) {
+	err := app.writeJSON(w, http.StatusOK, envelope{"providers": app.grocery.Names()}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// The showShoppingListHandler() returns the ingredients needed for the meal plan. If a
+// grocery provider is given, it also suggests products for each ingredient and builds a
+// ready-to-check-out cart from the best match for each required ingredient (or null, if
+// nothing matched).
+func (app *application) showShoppingListHandler(w http.ResponseWriter, r *http.Request) {
+	v := validator.New()
+
+	from, to := app.readMealPlanRange(r, v)
+
+	var provider grocery.Provider
+	if name := app.readString(r.URL.Query(), "provider", ""); name != "" {
+		provider = app.groceryProvider(v, name)
+	}
+
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	items, err := app.models.MealPlans.GetShoppingList(app.contextGetUser(r).ID, from, to)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	env := envelope{"shopping_list": items}
+
+	if provider != nil {
+		ingredients := make([]string, len(items))
+		optional := make(map[string]bool, len(items))
+		for i, item := range items {
+			ingredients[i] = item.Ingredient
+			optional[item.Ingredient] = item.Optional
+		}
+
+		matches, err := grocery.MatchIngredients(r.Context(), provider, ingredients, groceryMatchLimit)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+
+		cartItems := []grocery.CartItem{}
+		for _, match := range matches {
+			if !optional[match.Ingredient] && len(match.Products) > 0 {
+				cartItems = append(cartItems, grocery.CartItem{SKU: match.Products[0].SKU, Quantity: 1})
+			}
+		}
+
+		env["matches"] = matches
+		env["cart"] = nil
+
+		if len(cartItems) > 0 {
+			cart, err := provider.ExportCart(r.Context(), cartItems)
+			if err != nil {
+				app.serverErrorResponse(w, r, err)
+				return
+			}
+			env["cart"] = cart
+		}
+	}
+
+	err = app.writeJSON(w, http.StatusOK, env, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) matchGroceryProductsHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Provider    string   `json:"provider"`
+		Ingredients []string `json:"ingredients"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+	provider := app.groceryProvider(v, input.Provider)
+	v.Check(len(input.Ingredients) > 0, "ingredients", "must contain at least one ingredient")
+	v.Check(len(input.Ingredients) <= maxCartItems, "ingredients", fmt.Sprintf("must not contain more than %d ingredients", maxCartItems))
+
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	matches, err := grocery.MatchIngredients(r.Context(), provider, input.Ingredients, groceryMatchLimit)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"matches": matches}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) exportGroceryCartHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Provider string             `json:"provider"`
+		Items    []grocery.CartItem `json:"items"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+	provider := app.groceryProvider(v, input.Provider)
+	v.Check(len(input.Items) > 0, "items", "must contain at least one item")
+	v.Check(len(input.Items) <= maxCartItems, "items", fmt.Sprintf("must not contain more than %d items", maxCartItems))
+	for _, item := range input.Items {
+		v.Check(item.SKU != "", "items", "must all have a sku")
+		v.Check(item.Quantity >= 1 && item.Quantity <= 99, "items", "must all have a quantity between 1 and 99")
+	}
+
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	cart, err := provider.ExportCart(r.Context(), input.Items)
+	if err != nil {
+		switch {
+		case errors.Is(err, grocery.ErrUnknownProduct):
+			v.AddError("items", err.Error())
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"cart": cart}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
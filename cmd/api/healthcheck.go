@@ -18,3 +18,17 @@ func (app *application) healthcheckHandler(w http.ResponseWriter, r *http.Reques
 		app.serverErrorResponse(w, r, err)
 	}
 }
+
+// The showConfigHandler() returns the effective configuration, along with the source
+// that each setting was taken from, with secrets redacted.
+func (app *application) showConfigHandler(w http.ResponseWriter, r *http.Request) {
+	env := envelope{
+		"config":  app.config.redacted(),
+		"sources": app.config.sources,
+	}
+
+	err := app.writeJSON(w, http.StatusOK, env, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
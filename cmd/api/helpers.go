@@ -9,7 +9,9 @@ import (
 	"net/url"
 	"strconv"
 	"strings"
+	"time"
 
+	"eatinn.dcashman.net/internal/data"
 	"eatinn.dcashman.net/internal/validator"
 
 	"github.com/julienschmidt/httprouter"
@@ -50,13 +52,35 @@ func (app *application) readIDParam(r *http.Request) (int64, error) {
 	return id, nil
 }
 
+// maxIdempotentBodyBytes bounds how much of a request body the idempotent() middleware
+// will buffer in order to fingerprint it.
+const maxIdempotentBodyBytes = 10 * 1_048_576
+
+// The readNamedIDParam() helper works like readIDParam(), but for routes with more
+// than one ID parameter, such as /v1/recipes/:id/gallery/:image_id.
+func (app *application) readNamedIDParam(r *http.Request, name string) (int64, error) {
+	params := httprouter.ParamsFromContext(r.Context())
+
+	id, err := strconv.ParseInt(params.ByName(name), 10, 64)
+	if err != nil || id < 1 {
+		return 0, fmt.Errorf("invalid %s parameter", name)
+	}
+
+	return id, nil
+}
+
 // Define an envelope type.
 type envelope map[string]any
 
 func (app *application) readJSON(w http.ResponseWriter, r *http.Request, dst any) error {
 	// Use http.MaxBytesReader() to limit the size of the request body to 1MB.
-	maxBytes := 1_048_576
-	r.Body = http.MaxBytesReader(w, r.Body, int64(maxBytes))
+	return app.readJSONWithLimit(w, r, dst, 1_048_576)
+}
+
+// The readJSONWithLimit() helper works like readJSON(), but for endpoints which need to
+// accept request bodies larger than 1MB, such as recipe imports.
+func (app *application) readJSONWithLimit(w http.ResponseWriter, r *http.Request, dst any, maxBytes int64) error {
+	r.Body = http.MaxBytesReader(w, r.Body, maxBytes)
 
 	// Initialize the json.Decoder, and call the DisallowUnknownFields() method on it
 	// before decoding. This means that if the JSON from the client now includes any
@@ -102,7 +126,7 @@ func (app *application) readJSON(w http.ResponseWriter, r *http.Request, dst any
 
 		// Use the errors.As() function to check whether the error has the type
 		// *http.MaxBytesError. If it does, then it means the request body exceeded our
-		// size limit of 1MB and we return a clear error message.
+		// size limit and we return a clear error message.
 		case errors.As(err, &maxBytesError):
 			return fmt.Errorf("body must not be larger than %d bytes", maxBytesError.Limit)
 
@@ -178,3 +202,20 @@ func (app *application) readInt(qs url.Values, key string, defaultValue int, v *
 
 	return i
 }
+
+// The readDate() helper reads a "2006-01-02" formatted date from the query string,
+// recording a validation error if it can't be parsed.
+func (app *application) readDate(qs url.Values, key string, defaultValue time.Time, v *validator.Validator) time.Time {
+	s := qs.Get(key)
+	if s == "" {
+		return defaultValue
+	}
+
+	t, err := time.Parse(data.DateLayout, s)
+	if err != nil {
+		v.AddError(key, "must be a date in the format YYYY-MM-DD")
+		return defaultValue
+	}
+
+	return t
+}
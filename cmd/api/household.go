@@ -0,0 +1,190 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"eatinn.dcashman.net/internal/data"
+	"eatinn.dcashman.net/internal/validator"
+)
+
+// The ownHouseholdMember() helper reads the member ID from the URL and fetches the
+// household member, checking that it belongs to the authenticated user. If not, an
+// appropriate error response is sent and nil is returned.
+func (app *application) ownHouseholdMember(w http.ResponseWriter, r *http.Request) *data.HouseholdMember {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return nil
+	}
+
+	member, err := app.models.Household.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return nil
+	}
+
+	// Household members are private, so other users' members are reported as missing.
+	if member.UserID != app.contextGetUser(r).ID {
+		app.notFoundResponse(w, r)
+		return nil
+	}
+
+	return member
+}
+
+func (app *application) createHouseholdMemberHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Name      string   `json:"name"`
+		Allergies []string `json:"allergies"`
+		Dislikes  []string `json:"dislikes"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	member := &data.HouseholdMember{
+		UserID:    app.contextGetUser(r).ID,
+		Name:      input.Name,
+		Allergies: input.Allergies,
+		Dislikes:  input.Dislikes,
+	}
+	if member.Allergies == nil {
+		member.Allergies = []string{}
+	}
+	if member.Dislikes == nil {
+		member.Dislikes = []string{}
+	}
+
+	member.TrimIngredients()
+
+	v := validator.New()
+	if data.ValidateHouseholdMember(v, member); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.Household.Insert(member)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	headers := make(http.Header)
+	headers.Set("Location", fmt.Sprintf("/v1/household/members/%d", member.ID))
+
+	err = app.writeJSON(w, http.StatusCreated, envelope{"member": member}, headers)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) listHouseholdMembersHandler(w http.ResponseWriter, r *http.Request) {
+	members, err := app.models.Household.GetAllForUser(app.contextGetUser(r).ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"members": members}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) showHouseholdMemberHandler(w http.ResponseWriter, r *http.Request) {
+	member := app.ownHouseholdMember(w, r)
+	if member == nil {
+		return
+	}
+
+	err := app.writeJSON(w, http.StatusOK, envelope{"member": member}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) updateHouseholdMemberHandler(w http.ResponseWriter, r *http.Request) {
+	member := app.ownHouseholdMember(w, r)
+	if member == nil {
+		return
+	}
+
+	var input struct {
+		Name      *string  `json:"name"`
+		Allergies []string `json:"allergies"`
+		Dislikes  []string `json:"dislikes"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if input.Name != nil {
+		member.Name = *input.Name
+	}
+	if input.Allergies != nil {
+		member.Allergies = input.Allergies
+	}
+	if input.Dislikes != nil {
+		member.Dislikes = input.Dislikes
+	}
+
+	member.TrimIngredients()
+
+	v := validator.New()
+	if data.ValidateHouseholdMember(v, member); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.Household.Update(member)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrEditConflict):
+			app.editConflictResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"member": member}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) deleteHouseholdMemberHandler(w http.ResponseWriter, r *http.Request) {
+	member := app.ownHouseholdMember(w, r)
+	if member == nil {
+		return
+	}
+
+	err := app.models.Household.Delete(member.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"message": "household member successfully deleted"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
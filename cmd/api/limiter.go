@@ -0,0 +1,187 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"eatinn.dcashman.net/internal/data"
+	"golang.org/x/time/rate"
+)
+
+// rateLimiter holds a token bucket for each client of the API. Every request is
+// counted against a bucket for its IP address, and requests from authenticated users
+// also against a bucket for their user ID, which they share across all of their
+// devices. Administrators can give individual users a lower rate or burst, which is
+// held in overrides and applies to the user ID bucket only.
+type rateLimiter struct {
+	mu        sync.Mutex
+	rps       float64
+	burst     int
+	clients   map[string]*rateLimitClient
+	overrides map[int64]*data.UserLimits
+}
+
+type rateLimitClient struct {
+	limiter  *rate.Limiter
+	userID   int64
+	lastSeen time.Time
+	allowed  int64
+	rejected int64
+}
+
+// rateLimitStatus is a snapshot of a client's consumption, as reported to
+// administrators.
+type rateLimitStatus struct {
+	Key        string    `json:"key"`
+	UserID     int64     `json:"user_id,omitempty"`
+	RPS        float64   `json:"rps"`
+	Burst      int       `json:"burst"`
+	Tokens     float64   `json:"tokens"`
+	Allowed    int64     `json:"allowed"`
+	Rejected   int64     `json:"rejected"`
+	LastSeen   time.Time `json:"last_seen"`
+	Overridden bool      `json:"overridden"`
+}
+
+// userRateLimitKey and ipRateLimitKey return the keys under which clients are tracked.
+func userRateLimitKey(userID int64) string {
+	return fmt.Sprintf("user:%d", userID)
+}
+
+func ipRateLimitKey(ip string) string {
+	return "ip:" + ip
+}
+
+// The newRateLimiter() function returns a rateLimiter using the given defaults and
+// per-user overrides. It also launches a background goroutine which removes clients
+// that haven't been seen within the last three minutes, once every minute.
+func newRateLimiter(rps float64, burst int, overrides []*data.UserLimits) *rateLimiter {
+	l := &rateLimiter{
+		rps:       rps,
+		burst:     burst,
+		clients:   make(map[string]*rateLimitClient),
+		overrides: make(map[int64]*data.UserLimits),
+	}
+
+	for _, limits := range overrides {
+		l.overrides[limits.UserID] = limits
+	}
+
+	go func() {
+		for {
+			time.Sleep(time.Minute)
+
+			l.mu.Lock()
+			for key, client := range l.clients {
+				if time.Since(client.lastSeen) > 3*time.Minute {
+					delete(l.clients, key)
+				}
+			}
+			l.mu.Unlock()
+		}
+	}()
+
+	return l
+}
+
+// limitsFor returns the rate and burst which apply to a user. It must be called with
+// the mutex held. A userID of 0 means an anonymous client.
+func (l *rateLimiter) limitsFor(userID int64) (float64, int, bool) {
+	rps, burst := l.rps, l.burst
+
+	limits, overridden := l.overrides[userID]
+	if overridden {
+		if limits.RPS != nil {
+			rps = *limits.RPS
+		}
+		if limits.Burst != nil {
+			burst = *limits.Burst
+		}
+	}
+
+	return rps, burst, overridden
+}
+
+// allow records a request from the client identified by key, and reports whether it
+// is within the client's limit.
+func (l *rateLimiter) allow(key string, userID int64) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	client, found := l.clients[key]
+	if !found {
+		rps, burst, _ := l.limitsFor(userID)
+		client = &rateLimitClient{limiter: rate.NewLimiter(rate.Limit(rps), burst), userID: userID}
+		l.clients[key] = client
+	}
+
+	client.lastSeen = time.Now()
+
+	if !client.limiter.Allow() {
+		client.rejected++
+		return false
+	}
+
+	client.allowed++
+	return true
+}
+
+// setOverride replaces a user's overrides, or removes them if limits is nil. Any
+// bucket the user already has is adjusted straight away, rather than when it expires.
+func (l *rateLimiter) setOverride(userID int64, limits *data.UserLimits) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if limits == nil {
+		delete(l.overrides, userID)
+	} else {
+		l.overrides[userID] = limits
+	}
+
+	rps, burst, _ := l.limitsFor(userID)
+	for _, client := range l.clients {
+		if client.userID == userID {
+			client.limiter.SetLimit(rate.Limit(rps))
+			client.limiter.SetBurst(burst)
+		}
+	}
+}
+
+// status returns a snapshot of every client seen in the last three minutes, sorted by
+// key. If key is not empty, only that client is included.
+func (l *rateLimiter) status(key string) []rateLimitStatus {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	statuses := []rateLimitStatus{}
+
+	for clientKey, client := range l.clients {
+		if key != "" && clientKey != key {
+			continue
+		}
+
+		_, _, overridden := l.limitsFor(client.userID)
+
+		statuses = append(statuses, rateLimitStatus{
+			Key:        clientKey,
+			UserID:     client.userID,
+			RPS:        float64(client.limiter.Limit()),
+			Burst:      client.limiter.Burst(),
+			Tokens:     client.limiter.TokensAt(now),
+			Allowed:    client.allowed,
+			Rejected:   client.rejected,
+			LastSeen:   client.lastSeen,
+			Overridden: overridden,
+		})
+	}
+
+	slices.SortFunc(statuses, func(a, b rateLimitStatus) int {
+		return strings.Compare(a.Key, b.Key)
+	})
+
+	return statuses
+}
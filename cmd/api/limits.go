@@ -0,0 +1,295 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"eatinn.dcashman.net/internal/data"
+	"eatinn.dcashman.net/internal/validator"
+)
+
+// effectiveLimits holds the limits which actually apply to a user, after combining
+// their overrides with the server-wide defaults. A nil quota means no limit.
+type effectiveLimits struct {
+	RPS           float64 `json:"rps"`
+	Burst         int     `json:"burst"`
+	MaxRecipes    *int64  `json:"max_recipes"`
+	MaxImageBytes *int64  `json:"max_image_bytes"`
+}
+
+func (app *application) effectiveLimits(limits *data.UserLimits) effectiveLimits {
+	effective := effectiveLimits{
+		RPS:   app.config.limiter.rps,
+		Burst: app.config.limiter.burst,
+	}
+
+	// In the server-wide defaults, unlike in overrides, a quota of 0 means no limit.
+	if app.config.quota.maxRecipes > 0 {
+		effective.MaxRecipes = &app.config.quota.maxRecipes
+	}
+	if app.config.quota.maxImageBytes > 0 {
+		effective.MaxImageBytes = &app.config.quota.maxImageBytes
+	}
+
+	if limits.RPS != nil {
+		effective.RPS = *limits.RPS
+	}
+	if limits.Burst != nil {
+		effective.Burst = *limits.Burst
+	}
+
+	switch {
+	case limits.UnlimitedRecipes:
+		effective.MaxRecipes = nil
+	case limits.MaxRecipes != nil:
+		effective.MaxRecipes = limits.MaxRecipes
+	}
+
+	switch {
+	case limits.UnlimitedImageBytes:
+		effective.MaxImageBytes = nil
+	case limits.MaxImageBytes != nil:
+		effective.MaxImageBytes = limits.MaxImageBytes
+	}
+
+	return effective
+}
+
+// The checkStorageQuota() helper checks that the user has room for the given number of
+// new recipes and bytes of new images. If not, a 403 Forbidden response is sent and
+// false is returned. The check isn't atomic with the insert which follows it, so
+// concurrent requests can take a user slightly over their quota.
+func (app *application) checkStorageQuota(w http.ResponseWriter, r *http.Request, user *data.User, recipes, imageBytes int64) bool {
+	limits, err := app.models.Limits.GetForUser(user.ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return false
+	}
+
+	effective := app.effectiveLimits(limits)
+	if effective.MaxRecipes == nil && effective.MaxImageBytes == nil {
+		return true
+	}
+
+	usage, err := app.models.Limits.GetUsage(user.ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return false
+	}
+
+	switch {
+	case recipes > 0 && effective.MaxRecipes != nil && usage.Recipes+recipes > *effective.MaxRecipes:
+		app.quotaExceededResponse(w, r, fmt.Sprintf("you have reached your quota of %d recipes", *effective.MaxRecipes))
+		return false
+	case imageBytes > 0 && effective.MaxImageBytes != nil && usage.ImageBytes+imageBytes > *effective.MaxImageBytes:
+		app.quotaExceededResponse(w, r, fmt.Sprintf("this would exceed your image storage quota of %d bytes", *effective.MaxImageBytes))
+		return false
+	}
+
+	return true
+}
+
+// The listRateLimitsHandler() reports the current rate limit consumption of every
+// client seen recently. The user_id or ip query string parameters narrow it down to a
+// single client.
+func (app *application) listRateLimitsHandler(w http.ResponseWriter, r *http.Request) {
+	qs := r.URL.Query()
+
+	v := validator.New()
+
+	var key string
+	switch {
+	case qs.Has("user_id"):
+		userID := app.readInt(qs, "user_id", 0, v)
+		v.Check(userID > 0, "user_id", "must be a positive integer")
+		key = userRateLimitKey(int64(userID))
+	case qs.Has("ip"):
+		key = ipRateLimitKey(app.readString(qs, "ip", ""))
+	}
+
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	env := envelope{
+		"limiter": map[string]any{
+			"enabled": app.config.limiter.enabled,
+			"rps":     app.config.limiter.rps,
+			"burst":   app.config.limiter.burst,
+		},
+		"clients": app.limiter.status(key),
+	}
+
+	err := app.writeJSON(w, http.StatusOK, env, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// The limitsUser() helper reads the user ID from the URL and fetches the user. If the
+// user doesn't exist an error response is sent and nil is returned.
+func (app *application) limitsUser(w http.ResponseWriter, r *http.Request) *data.User {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return nil
+	}
+
+	user, err := app.models.Users.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return nil
+	}
+
+	return user
+}
+
+// The writeUserLimits() helper sends a user's overrides along with the limits that
+// actually apply to them, their storage usage and their current rate limit bucket (or
+// null if they haven't made a request recently).
+func (app *application) writeUserLimits(w http.ResponseWriter, r *http.Request, limits *data.UserLimits) {
+	usage, err := app.models.Limits.GetUsage(limits.UserID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	var rateLimit *rateLimitStatus
+	if statuses := app.limiter.status(userRateLimitKey(limits.UserID)); len(statuses) > 0 {
+		rateLimit = &statuses[0]
+	}
+
+	env := envelope{
+		"limits":     limits,
+		"effective":  app.effectiveLimits(limits),
+		"usage":      usage,
+		"rate_limit": rateLimit,
+	}
+
+	err = app.writeJSON(w, http.StatusOK, env, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) showUserLimitsHandler(w http.ResponseWriter, r *http.Request) {
+	user := app.limitsUser(w, r)
+	if user == nil {
+		return
+	}
+
+	limits, err := app.models.Limits.GetForUser(user.ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	app.writeUserLimits(w, r, limits)
+}
+
+// The updateUserLimitsHandler() replaces a user's overrides. Omitted or null fields
+// revert to the server-wide defaults. Changes to the rate limit apply to the user's
+// next request, and may only lower it, since the per-IP limit still applies.
+//
+// The X-Expected-Version header is required, so that administrators editing the same
+// user can't silently overwrite each other's changes. It is the version returned with
+// the overrides, which is 0 for a user who has none yet.
+func (app *application) updateUserLimitsHandler(w http.ResponseWriter, r *http.Request) {
+	user := app.limitsUser(w, r)
+	if user == nil {
+		return
+	}
+
+	expectedVersion := r.Header.Get("X-Expected-Version")
+	if expectedVersion == "" {
+		app.badRequestResponse(w, r, errors.New("the X-Expected-Version header must be provided"))
+		return
+	}
+
+	limits, err := app.models.Limits.GetForUser(user.ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if strconv.FormatInt(int64(limits.Version), 10) != expectedVersion {
+		app.editConflictResponse(w, r)
+		return
+	}
+
+	var input struct {
+		RPS                 *float64 `json:"rps"`
+		Burst               *int     `json:"burst"`
+		MaxRecipes          *int64   `json:"max_recipes"`
+		UnlimitedRecipes    bool     `json:"unlimited_recipes"`
+		MaxImageBytes       *int64   `json:"max_image_bytes"`
+		UnlimitedImageBytes bool     `json:"unlimited_image_bytes"`
+	}
+
+	err = app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	limits.RPS = input.RPS
+	limits.Burst = input.Burst
+	limits.MaxRecipes = input.MaxRecipes
+	limits.UnlimitedRecipes = input.UnlimitedRecipes
+	limits.MaxImageBytes = input.MaxImageBytes
+	limits.UnlimitedImageBytes = input.UnlimitedImageBytes
+
+	v := validator.New()
+	if data.ValidateUserLimits(v, limits, app.config.limiter.rps, app.config.limiter.burst); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.Limits.Upsert(limits)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrEditConflict):
+			app.editConflictResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	app.limiter.setOverride(user.ID, limits)
+
+	app.writeUserLimits(w, r, limits)
+}
+
+func (app *application) deleteUserLimitsHandler(w http.ResponseWriter, r *http.Request) {
+	user := app.limitsUser(w, r)
+	if user == nil {
+		return
+	}
+
+	err := app.models.Limits.Delete(user.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	app.limiter.setOverride(user.ID, nil)
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"message": "limits successfully reset to the defaults"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
@@ -11,6 +11,7 @@ import (
 	"time"
 
 	"eatinn.dcashman.net/internal/data"
+	"eatinn.dcashman.net/internal/grocery"
 	"eatinn.dcashman.net/internal/mailer"
 
 	// Import the pq driver so that it can register itself with the database/sql
@@ -22,6 +23,7 @@ import (
 const version = "0.1.0"
 
 type config struct {
+	file string
 	port int
 	env  string
 	db   struct {
@@ -35,6 +37,10 @@ type config struct {
 		burst   int
 		enabled bool
 	}
+	quota struct {
+		maxRecipes    int64
+		maxImageBytes int64
+	}
 	smtp struct {
 		host     string
 		port     int
@@ -45,22 +51,42 @@ type config struct {
 	cors struct {
 		trustedOrigins []string
 	}
+	tls struct {
+		certFile         string
+		keyFile          string
+		autocertDomains  []string
+		autocertCacheDir string
+	}
+	scheduler struct {
+		publishInterval time.Duration
+	}
+	grocery struct {
+		catalogs      []string
+		shopifyStores []string
+	}
+	// sources records where each setting's effective value came from, keyed by flag
+	// name. It is populated by loadConfig().
+	sources map[string]string
 }
 
 type application struct {
-	config config
-	logger *slog.Logger
-	models data.Models
-	mailer mailer.Mailer
-	wg     sync.WaitGroup
+	config  config
+	logger  *slog.Logger
+	models  data.Models
+	mailer  mailer.Mailer
+	grocery *grocery.Registry
+	limiter *rateLimiter
+	wg      sync.WaitGroup
 }
 
 func main() {
 	var cfg config
 
+	flag.StringVar(&cfg.file, "config", "", "Path to a JSON configuration file")
+
 	flag.IntVar(&cfg.port, "port", 4000, "API server port")
 	flag.StringVar(&cfg.env, "env", "development", "Environment (development|staging|production)")
-	flag.StringVar(&cfg.db.dsn, "db-dsn", os.Getenv("EATINN_DB_DSN"), "PostgreSQL DSN")
+	flag.StringVar(&cfg.db.dsn, "db-dsn", "", "PostgreSQL DSN")
 
 	// Read the connection pool settings from command-line flags into the config struct.
 	// Notice that the default values we're using are the ones we discussed above?
@@ -73,6 +99,11 @@ func main() {
 	flag.IntVar(&cfg.limiter.burst, "limiter-burst", 4, "Rate limiter maximum burst")
 	flag.BoolVar(&cfg.limiter.enabled, "limiter-enabled", true, "Enable rate limiter")
 
+	// Storage quota settings. These are the defaults for every user, and can be
+	// overridden for individual users through the admin API.
+	flag.Int64Var(&cfg.quota.maxRecipes, "quota-max-recipes", 0, "Maximum recipes each user may create (0 for no limit)")
+	flag.Int64Var(&cfg.quota.maxImageBytes, "quota-max-image-bytes", 0, "Maximum total bytes of images each user may upload (0 for no limit)")
+
 	// SMTP settings
 	flag.StringVar(&cfg.smtp.host, "smtp-host", "sandbox.smtp.mailtrap.io", "SMTP host")
 	flag.IntVar(&cfg.smtp.port, "smtp-port", 2525, "SMTP port")
@@ -86,10 +117,53 @@ func main() {
 		return nil
 	})
 
+	// TLS settings
+	flag.StringVar(&cfg.tls.certFile, "tls-cert-file", "", "TLS certificate file (PEM)")
+	flag.StringVar(&cfg.tls.keyFile, "tls-key-file", "", "TLS private key file (PEM)")
+	flag.Func("tls-autocert-domains", "Domains to obtain Let's Encrypt certificates for (space separated)", func(val string) error {
+		cfg.tls.autocertDomains = strings.Fields(val)
+		return nil
+	})
+	flag.StringVar(&cfg.tls.autocertCacheDir, "tls-autocert-cache-dir", "certs", "Directory for caching Let's Encrypt certificates")
+
+	// Scheduler settings
+	flag.DurationVar(&cfg.scheduler.publishInterval, "publish-interval", time.Minute, "How often to publish recipes whose publish_at time has passed")
+
+	// Grocery settings
+	flag.Func("grocery-catalogs", "Grocery product catalog files to match ingredients against (space separated)", func(val string) error {
+		cfg.grocery.catalogs = strings.Fields(val)
+		return nil
+	})
+	flag.Func("grocery-shopify-stores", "Shopify store settings files to match ingredients against and export carts to (space separated)", func(val string) error {
+		cfg.grocery.shopifyStores = strings.Fields(val)
+		return nil
+	})
+
 	flag.Parse()
 
 	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
 
+	// Layer the config file and EATINN_* environment variables underneath the
+	// command-line flags, then check that the result makes sense before going any
+	// further.
+	err := loadConfig(flag.CommandLine, &cfg)
+	if err != nil {
+		logger.Error(err.Error())
+		os.Exit(1)
+	}
+
+	err = validateConfig(cfg)
+	if err != nil {
+		logger.Error(err.Error())
+		os.Exit(1)
+	}
+
+	groceryProviders, err := openGroceryProviders(cfg)
+	if err != nil {
+		logger.Error(err.Error())
+		os.Exit(1)
+	}
+
 	db, err := openDB(cfg)
 	if err != nil {
 		logger.Error(err.Error())
@@ -104,11 +178,23 @@ func main() {
 	// established.
 	logger.Info("database connection pool established")
 
+	models := data.NewModels(db)
+
+	// Load the per-user rate limit overrides set by administrators, so that they apply
+	// from the first request.
+	userLimits, err := models.Limits.GetAll()
+	if err != nil {
+		logger.Error(err.Error())
+		os.Exit(1)
+	}
+
 	app := &application{
-		config: cfg,
-		logger: logger,
-		models: data.NewModels(db),
-		mailer: mailer.New(cfg.smtp.host, cfg.smtp.port, cfg.smtp.username, cfg.smtp.password, cfg.smtp.sender),
+		config:  cfg,
+		logger:  logger,
+		models:  models,
+		mailer:  mailer.New(cfg.smtp.host, cfg.smtp.port, cfg.smtp.username, cfg.smtp.password, cfg.smtp.sender),
+		grocery: groceryProviders,
+		limiter: newRateLimiter(cfg.limiter.rps, cfg.limiter.burst, userLimits),
 	}
 
 	// Use the httprouter instance returned by app.routes() as the server handler.
@@ -158,3 +244,28 @@ func openDB(cfg config) (*sql.DB, error) {
 	// Return the sql.DB connection pool.
 	return db, nil
 }
+
+// The openGroceryProviders() function loads the configured grocery catalogs and
+// Shopify stores. Other grocery.Provider implementations should be added to the
+// registry here.
+func openGroceryProviders(cfg config) (*grocery.Registry, error) {
+	providers := []grocery.Provider{}
+
+	for _, path := range cfg.grocery.catalogs {
+		catalog, err := grocery.LoadCatalog(path)
+		if err != nil {
+			return nil, err
+		}
+		providers = append(providers, catalog)
+	}
+
+	for _, path := range cfg.grocery.shopifyStores {
+		store, err := grocery.LoadShopify(path)
+		if err != nil {
+			return nil, err
+		}
+		providers = append(providers, store)
+	}
+
+	return grocery.NewRegistry(providers...)
+}
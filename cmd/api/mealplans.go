@@ -0,0 +1,256 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"eatinn.dcashman.net/internal/data"
+	"eatinn.dcashman.net/internal/validator"
+)
+
+// maxMealPlanDays is the longest date range that can be requested from the meal plan
+// endpoints in one go.
+const maxMealPlanDays = 31
+
+// The readMealPlanRange() helper reads the from and to query string parameters,
+// defaulting to the week starting today.
+func (app *application) readMealPlanRange(r *http.Request, v *validator.Validator) (time.Time, time.Time) {
+	qs := r.URL.Query()
+
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+
+	from := app.readDate(qs, "from", today, v)
+	to := app.readDate(qs, "to", from.AddDate(0, 0, 6), v)
+
+	data.ValidateDateRange(v, from, to, maxMealPlanDays)
+
+	return from, to
+}
+
+func (app *application) createMealPlanEntryHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		RecipeID   int64     `json:"recipe_id"`
+		PlannedFor data.Date `json:"planned_for"`
+		Meal       string    `json:"meal"`
+		Servings   *float64  `json:"servings"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	user := app.contextGetUser(r)
+
+	entry := &data.MealPlanEntry{
+		UserID:     user.ID,
+		RecipeID:   input.RecipeID,
+		PlannedFor: input.PlannedFor,
+		Meal:       input.Meal,
+		Servings:   1,
+	}
+	if input.Servings != nil {
+		entry.Servings = *input.Servings
+	}
+
+	v := validator.New()
+	if data.ValidateMealPlanEntry(v, entry); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	// Only recipes which the user can see may be added to their meal plan.
+	recipe, err := app.models.Recipes.Get(entry.RecipeID)
+	if err != nil && !errors.Is(err, data.ErrRecordNotFound) {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	if recipe == nil || !recipe.VisibleTo(user) {
+		v.AddError("recipe_id", "recipe not found")
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+	entry.RecipeName = recipe.Name
+
+	err = app.models.MealPlans.Insert(entry)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	headers := make(http.Header)
+	headers.Set("Location", fmt.Sprintf("/v1/meal-plan/entries/%d", entry.ID))
+
+	err = app.writeJSON(w, http.StatusCreated, envelope{"entry": entry}, headers)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) listMealPlanEntriesHandler(w http.ResponseWriter, r *http.Request) {
+	v := validator.New()
+
+	from, to := app.readMealPlanRange(r, v)
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	entries, err := app.models.MealPlans.GetAllForUser(app.contextGetUser(r).ID, from, to)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"entries": entries}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) deleteMealPlanEntryHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	entry, err := app.models.MealPlans.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	// Meal plans are private, so other users' entries are reported as missing.
+	if entry.UserID != app.contextGetUser(r).ID {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	err = app.models.MealPlans.Delete(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"message": "meal plan entry successfully deleted"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) showMealPlanNutritionHandler(w http.ResponseWriter, r *http.Request) {
+	v := validator.New()
+
+	from, to := app.readMealPlanRange(r, v)
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	user := app.contextGetUser(r)
+
+	goals, err := app.models.NutritionGoals.GetForUser(user.ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	days, err := app.models.MealPlans.GetDailyNutrition(user.ID, from, to)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	for _, day := range days {
+		day.CompareToGoals(goals)
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"goals": goals, "days": days}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) showNutritionGoalsHandler(w http.ResponseWriter, r *http.Request) {
+	goals, err := app.models.NutritionGoals.GetForUser(app.contextGetUser(r).ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"nutrition_goals": goals}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) updateNutritionGoalsHandler(w http.ResponseWriter, r *http.Request) {
+	goals, err := app.models.NutritionGoals.GetForUser(app.contextGetUser(r).ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	// Goals are partially updatable, with a zero value clearing that goal.
+	var input struct {
+		Calories          *float64 `json:"calories"`
+		ProteinGrams      *float64 `json:"protein_grams"`
+		CarbohydrateGrams *float64 `json:"carbohydrate_grams"`
+		FatGrams          *float64 `json:"fat_grams"`
+	}
+
+	err = app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if input.Calories != nil {
+		goals.Calories = *input.Calories
+	}
+	if input.ProteinGrams != nil {
+		goals.ProteinGrams = *input.ProteinGrams
+	}
+	if input.CarbohydrateGrams != nil {
+		goals.CarbohydrateGrams = *input.CarbohydrateGrams
+	}
+	if input.FatGrams != nil {
+		goals.FatGrams = *input.FatGrams
+	}
+
+	v := validator.New()
+	if data.ValidateNutrition(v, &goals.Nutrition); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.NutritionGoals.Upsert(goals)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrEditConflict):
+			app.editConflictResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"nutrition_goals": goals}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
@@ -1,17 +1,17 @@
 package main
 
 import (
+	"bytes"
+	"crypto/sha256"
 	"errors"
 	"fmt"
+	"io"
 	"net"
 	"net/http"
 	"strings"
-	"sync"
-	"time"
 
 	"eatinn.dcashman.net/internal/data"
 	"eatinn.dcashman.net/internal/validator"
-	"golang.org/x/time/rate"
 )
 
 func (app *application) recoverPanic(next http.Handler) http.Handler {
@@ -40,43 +40,10 @@ func (app *application) recoverPanic(next http.Handler) http.Handler {
 	})
 }
 
+// The rateLimit() middleware limits every request by IP address. It runs before
+// authenticate(), so that requests with invalid tokens are limited too and can't be
+// used to flood the database with token lookups.
 func (app *application) rateLimit(next http.Handler) http.Handler {
-	// Define a client struct to hold the rate limiter and last seen time for each
-	// client.
-	type client struct {
-		limiter  *rate.Limiter
-		lastSeen time.Time
-	}
-
-	var (
-		mu sync.Mutex
-		// Update the map so the values are pointers to a client struct.
-		clients = make(map[string]*client)
-	)
-
-	// Launch a background goroutine which removes old entries from the clients map once
-	// every minute.
-	go func() {
-		for {
-			time.Sleep(time.Minute)
-
-			// Lock the mutex to prevent any rate limiter checks from happening while
-			// the cleanup is taking place.
-			mu.Lock()
-
-			// Loop through all clients. If they haven't been seen within the last three
-			// minutes, delete the corresponding entry from the map.
-			for ip, client := range clients {
-				if time.Since(client.lastSeen) > 3*time.Minute {
-					delete(clients, ip)
-				}
-			}
-
-			// Importantly, unlock the mutex when the cleanup is complete.
-			mu.Unlock()
-		}
-	}()
-
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		if app.config.limiter.enabled {
 			ip, _, err := net.SplitHostPort(r.RemoteAddr)
@@ -85,23 +52,27 @@ func (app *application) rateLimit(next http.Handler) http.Handler {
 				return
 			}
 
-			mu.Lock()
-
-			if _, found := clients[ip]; !found {
-				// Create and add a new client struct to the map if it doesn't already exist.
-				clients[ip] = &client{limiter: rate.NewLimiter(rate.Limit(app.config.limiter.rps), app.config.limiter.burst)}
+			if !app.limiter.allow(ipRateLimitKey(ip), 0) {
+				app.rateLimitExceededResponse(w, r)
+				return
 			}
+		}
 
-			// Update the last seen time for the client.
-			clients[ip].lastSeen = time.Now()
+		next.ServeHTTP(w, r)
+	})
+}
+
+// The rateLimitUser() middleware additionally limits authenticated users by their user
+// ID, applying any overrides set by administrators. It must come after authenticate().
+func (app *application) rateLimitUser(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user := app.contextGetUser(r)
 
-			if !clients[ip].limiter.Allow() {
-				mu.Unlock()
+		if app.config.limiter.enabled && !user.IsAnonymous() {
+			if !app.limiter.allow(userRateLimitKey(user.ID), user.ID) {
 				app.rateLimitExceededResponse(w, r)
 				return
 			}
-
-			mu.Unlock()
 		}
 
 		next.ServeHTTP(w, r)
@@ -211,6 +182,153 @@ func (app *application) requireActivatedUser(next http.HandlerFunc) http.Handler
 	return app.requireAuthenticatedUser(fn)
 }
 
+// Checks that the user is activated and has been granted the given permission code.
+func (app *application) requirePermission(code string, next http.HandlerFunc) http.HandlerFunc {
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		// Retrieve the user from the request context.
+		user := app.contextGetUser(r)
+
+		// Get the slice of permissions for the user.
+		permissions, err := app.models.Permissions.GetAllForUser(user.ID)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+
+		// Check if the slice includes the required permission. If it doesn't, then
+		// return a 403 Forbidden response.
+		if !permissions.Include(code) {
+			app.notPermittedResponse(w, r)
+			return
+		}
+
+		// Otherwise they have the required permission so we call the next handler in
+		// the chain.
+		next.ServeHTTP(w, r)
+	}
+
+	// Wrap this with the requireActivatedUser() middleware before returning it.
+	return app.requireActivatedUser(fn)
+}
+
+// The idempotencyRecorder type wraps a http.ResponseWriter, capturing the status code
+// and body as they are written so that the response can be stored and replayed.
+type idempotencyRecorder struct {
+	http.ResponseWriter
+	statusCode int
+	body       bytes.Buffer
+}
+
+func (rec *idempotencyRecorder) WriteHeader(statusCode int) {
+	rec.statusCode = statusCode
+	rec.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (rec *idempotencyRecorder) Write(b []byte) (int, error) {
+	if rec.statusCode == 0 {
+		rec.statusCode = http.StatusOK
+	}
+	rec.body.Write(b)
+	return rec.ResponseWriter.Write(b)
+}
+
+// The idempotent() middleware lets clients safely retry a non-idempotent request by
+// sending an Idempotency-Key header. The first request with a given key is processed
+// as normal and its response stored; later requests from the same user with the same
+// key and an identical body receive the stored response instead of being processed
+// again. It must be wrapped by requireAuthenticatedUser() or requireActivatedUser().
+func (app *application) idempotent(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get("Idempotency-Key")
+		if key == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		v := validator.New()
+		if data.ValidateIdempotencyKey(v, key); !v.Valid() {
+			app.failedValidationResponse(w, r, v.Errors)
+			return
+		}
+
+		// Buffer the request body so that it can be fingerprinted, then put it back
+		// for the handler to read. The handler will apply its own size limit.
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxIdempotentBodyBytes))
+		if err != nil {
+			var maxBytesError *http.MaxBytesError
+			if errors.As(err, &maxBytesError) {
+				app.badRequestResponse(w, r, fmt.Errorf("body must not be larger than %d bytes", maxBytesError.Limit))
+				return
+			}
+			app.badRequestResponse(w, r, err)
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		hash := sha256.New()
+		hash.Write([]byte(r.Method + " " + r.URL.Path + "\n"))
+		hash.Write(body)
+		fingerprint := hash.Sum(nil)
+
+		user := app.contextGetUser(r)
+
+		record, created, err := app.models.Idempotency.Reserve(user.ID, key, fingerprint)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+
+		if !created {
+			switch {
+			case !bytes.Equal(record.RequestHash, fingerprint):
+				app.idempotencyKeyReusedResponse(w, r)
+			case record.StatusCode == 0:
+				app.idempotencyKeyInProgressResponse(w, r)
+			default:
+				for name, value := range record.ResponseHeaders {
+					w.Header()[name] = value
+				}
+				w.Header().Set("Idempotent-Replayed", "true")
+				w.WriteHeader(record.StatusCode)
+				w.Write(record.ResponseBody)
+			}
+			return
+		}
+
+		// Release the key if the handler fails with a server error (or panics), so
+		// that the client is able to retry the request.
+		completed := false
+		defer func() {
+			if !completed {
+				err := app.models.Idempotency.Delete(user.ID, key)
+				if err != nil {
+					app.logError(r, err)
+				}
+			}
+		}()
+
+		rec := &idempotencyRecorder{ResponseWriter: w}
+		next.ServeHTTP(rec, r)
+
+		if rec.statusCode >= http.StatusInternalServerError {
+			return
+		}
+
+		record.StatusCode = rec.statusCode
+		record.ResponseHeaders = w.Header().Clone()
+		record.ResponseBody = rec.body.Bytes()
+
+		err = app.models.Idempotency.Complete(record)
+		if err != nil {
+			// The response has already been sent, so all we can do is log the error.
+			app.logError(r, err)
+			return
+		}
+
+		completed = true
+	}
+}
+
 func (app *application) enableCORS(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Add("Vary", "Origin")
@@ -232,7 +350,7 @@ func (app *application) enableCORS(next http.Handler) http.Handler {
 						// Set the necessary preflight response headers, as discussed
 						// previously.
 						w.Header().Set("Access-Control-Allow-Methods", "OPTIONS, PUT, PATCH, DELETE")
-						w.Header().Set("Access-Control-Allow-Headers", "Authorization, Content-Type")
+						w.Header().Set("Access-Control-Allow-Headers", "Authorization, Content-Type, Idempotency-Key, X-Expected-Version")
 
 						// Write the headers along with a 200 OK status and return from
 						// the middleware with no further action.
@@ -0,0 +1,343 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+
+	"eatinn.dcashman.net/internal/data"
+	"eatinn.dcashman.net/internal/validator"
+	"github.com/julienschmidt/httprouter"
+)
+
+// maxRecipeFileBytes is the largest .eatinn file which can be imported. It matches the
+// limit of the idempotent() middleware, which buffers the body of import requests.
+const maxRecipeFileBytes = maxIdempotentBodyBytes
+
+// maxEmbeddedImageBytes bounds the total size of the images embedded in an exported
+// file. Base64 encoding grows the data by a third, so this leaves room for the rest of
+// the recipe within maxRecipeFileBytes. Images beyond the limit are exported as URLs.
+const maxEmbeddedImageBytes = 6 * 1_048_576
+
+// maxRemoteImageFetches and maxExportDuration bound the work a single export can make
+// the server do fetching remote images. Images beyond the limits are exported as URLs.
+const (
+	maxRemoteImageFetches = 5
+	maxExportDuration     = 15 * time.Second
+)
+
+// recipeFileContentType is the media type of .eatinn files.
+const recipeFileContentType = "application/vnd.eatinn.recipe+json"
+
+// imageFetchClient is used to download remote images for embedding in exported files.
+// Since the URLs are user-supplied, it refuses to connect to loopback, private and
+// link-local addresses so that it can't be used to probe the server's network.
+var imageFetchClient = &http.Client{
+	Timeout: 10 * time.Second,
+	Transport: &http.Transport{
+		DialContext: (&net.Dialer{
+			Timeout: 5 * time.Second,
+			Control: publicAddressesOnly,
+		}).DialContext,
+		TLSHandshakeTimeout:   5 * time.Second,
+		ResponseHeaderTimeout: 5 * time.Second,
+	},
+	CheckRedirect: func(req *http.Request, via []*http.Request) error {
+		if len(via) >= 3 {
+			return errors.New("too many redirects")
+		}
+		return nil
+	},
+}
+
+// publicAddressesOnly is a net.Dialer Control function which rejects connections to
+// non-public IP addresses. It runs after DNS resolution, so it can't be bypassed with a
+// hostname which resolves to a private address.
+func publicAddressesOnly(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+
+	ip := net.ParseIP(host)
+	if ip == nil || ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() || ip.IsMulticast() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() {
+		return fmt.Errorf("refusing to connect to non-public address %s", host)
+	}
+
+	return nil
+}
+
+// The fetchImage() helper downloads a remote image of at most maxBytes, returning its
+// data and sniffed content type.
+func (app *application) fetchImage(ctx context.Context, imageURL string, maxBytes int) ([]byte, string, error) {
+	u, err := url.Parse(imageURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return nil, "", fmt.Errorf("unsupported image url %q", imageURL)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, "", err
+	}
+
+	res, err := imageFetchClient.Do(req)
+	if err != nil {
+		return nil, "", err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, "", fmt.Errorf("unexpected status %d fetching %q", res.StatusCode, imageURL)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(res.Body, int64(maxBytes)+1))
+	if err != nil {
+		return nil, "", err
+	}
+	if len(body) > maxBytes {
+		return nil, "", fmt.Errorf("image %q is larger than %d bytes", imageURL, maxBytes)
+	}
+
+	contentType := http.DetectContentType(body)
+	if !validator.PermittedValue(contentType, data.ImageContentTypeSafelist...) {
+		return nil, "", fmt.Errorf("image %q has unsupported content type %s", imageURL, contentType)
+	}
+
+	return body, contentType, nil
+}
+
+// The embedImages() helper replaces the URL references in an exported file with the
+// image data, fetching images stored by eatinn from the database and others over HTTP.
+// Images which can't be embedded are left as URLs, since a missing image shouldn't stop
+// the rest of the recipe being exported. Embedding stops once maxEmbeddedImageBytes,
+// maxRemoteImageFetches or maxExportDuration is used up, and remote images are never
+// downloaded beyond the remaining byte budget.
+func (app *application) embedImages(ctx context.Context, f *data.RecipeFile) {
+	ctx, cancel := context.WithTimeout(ctx, maxExportDuration)
+	defer cancel()
+
+	remaining := maxEmbeddedImageBytes
+	fetches := 0
+
+	for _, image := range f.Images() {
+		if remaining <= 0 || ctx.Err() != nil {
+			break
+		}
+
+		var body []byte
+		var contentType string
+		var err error
+
+		if key, ok := strings.CutPrefix(image.URL, "/v1/images/"); ok {
+			var stored *data.StoredImage
+			stored, err = app.models.Images.Get(key)
+			if err == nil {
+				body, contentType = stored.Data, stored.ContentType
+			}
+		} else {
+			if fetches >= maxRemoteImageFetches {
+				continue
+			}
+			fetches++
+			body, contentType, err = app.fetchImage(ctx, image.URL, min(remaining, data.MaxImageBytes))
+		}
+
+		if err != nil {
+			app.logger.Warn("could not embed image in recipe export", "url", image.URL, "error", err.Error())
+			continue
+		}
+
+		if len(body) > remaining {
+			continue
+		}
+		remaining -= len(body)
+
+		image.URL = ""
+		image.ContentType = contentType
+		image.Data = body
+	}
+}
+
+// recipeFileName returns a file name for an exported recipe, based on its name.
+func recipeFileName(recipe *data.Recipe) string {
+	name := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9':
+			return r
+		case r >= 'A' && r <= 'Z':
+			return r + ('a' - 'A')
+		default:
+			return '-'
+		}
+	}, recipe.Name)
+
+	name = strings.Trim(name, "-")
+	for strings.Contains(name, "--") {
+		name = strings.ReplaceAll(name, "--", "-")
+	}
+
+	if name == "" {
+		name = fmt.Sprintf("recipe-%d", recipe.ID)
+	}
+
+	return name + data.RecipeFileExtension
+}
+
+func (app *application) exportRecipeHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	recipe, err := app.models.Recipes.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	if !recipe.VisibleTo(app.contextGetUser(r)) {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	f := data.NewRecipeFile(recipe)
+	app.embedImages(r.Context(), f)
+
+	js, err := json.MarshalIndent(f, "", "\t")
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", recipeFileContentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", recipeFileName(recipe)))
+	w.WriteHeader(http.StatusOK)
+	w.Write(append(js, '\n'))
+}
+
+func (app *application) importRecipeHandler(w http.ResponseWriter, r *http.Request) {
+	var f data.RecipeFile
+
+	err := app.readJSONWithLimit(w, r, &f, maxRecipeFileBytes)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	user := app.contextGetUser(r)
+
+	v := validator.New()
+	data.ValidateRecipeFile(v, &f)
+
+	// Work out the type of each embedded image from its content, rather than trusting
+	// the file.
+	stored := make(map[*data.RecipeFileImage]*data.StoredImage)
+	for _, image := range f.Images() {
+		if len(image.Data) == 0 {
+			continue
+		}
+
+		storedImage := &data.StoredImage{
+			UserID:      user.ID,
+			ContentType: http.DetectContentType(image.Data),
+			Data:        image.Data,
+		}
+		v.Check(validator.PermittedValue(storedImage.ContentType, data.ImageContentTypeSafelist...), "recipe.images", "must be JPEG, PNG, GIF or WebP images")
+		stored[image] = storedImage
+	}
+
+	if data.ValidateRecipe(v, f.ToRecipe(user.ID)); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	var imageBytes int64
+	for _, storedImage := range stored {
+		imageBytes += int64(len(storedImage.Data))
+	}
+
+	if !app.checkStorageQuota(w, r, user, 1, imageBytes) {
+		return
+	}
+
+	// The images are stored along with the recipe, but they need their keys first so
+	// that the recipe can refer to them by URL.
+	images := make([]*data.StoredImage, 0, len(stored))
+	for image, storedImage := range stored {
+		storedImage.Key, err = data.NewImageKey()
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+
+		image.URL = storedImage.URL()
+		image.Data = nil
+		images = append(images, storedImage)
+	}
+
+	recipe := f.ToRecipe(user.ID)
+
+	err = app.models.Recipes.InsertWithImages(recipe, images)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	headers := make(http.Header)
+	headers.Set("Location", fmt.Sprintf("/v1/recipes/%d", recipe.ID))
+
+	err = app.writeJSON(w, http.StatusCreated, envelope{"recipe": recipe}, headers)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) showImageHandler(w http.ResponseWriter, r *http.Request) {
+	key := httprouter.ParamsFromContext(r.Context()).ByName("key")
+
+	image, err := app.models.Images.Get(key)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	// Images of private recipes are only served to users who can see the recipe, and
+	// are reported as missing to everyone else.
+	visible, err := app.models.Images.VisibleTo(image, app.contextGetUser(r).ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	if !visible {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	// Stored images never change, but the recipes using them can be made private, so
+	// they are only cached by the viewer's own browser and only for a limited time.
+	w.Header().Set("Content-Type", image.ContentType)
+	w.Header().Set("Cache-Control", "private, max-age=3600")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(http.StatusOK)
+	w.Write(image.Data)
+}
@@ -1,9 +1,12 @@
 package main
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
 	"eatinn.dcashman.net/internal/data"
@@ -29,6 +32,13 @@ func (app *application) showRecipeHandler(w http.ResponseWriter, r *http.Request
 		return
 	}
 
+	// Private recipes are reported as missing to anyone but their creator, so that
+	// we don't leak their existence.
+	if !recipe.VisibleTo(app.contextGetUser(r)) {
+		app.notFoundResponse(w, r)
+		return
+	}
+
 	// Encode the struct to JSON and send it as the HTTP response.
 	err = app.writeJSON(w, http.StatusOK, envelope{"recipe": recipe}, nil)
 	if err != nil {
@@ -52,7 +62,9 @@ func (app *application) createRecipeHandler(w http.ResponseWriter, r *http.Reque
 		PrepTime          data.Duration          `json:"prep_time"`
 		ActiveTime        data.Duration          `json:"active_time"`
 		Public            bool                   `json:"public"`
+		PublishAt         *time.Time             `json:"publish_at"`
 		Servings          int32                  `json:"servings"`
+		Nutrition         *data.Nutrition        `json:"nutrition"`
 	}
 
 	err := app.readJSON(w, r, &input)
@@ -77,17 +89,26 @@ func (app *application) createRecipeHandler(w http.ResponseWriter, r *http.Reque
 		PrepTime:          input.PrepTime,
 		ActiveTime:        input.ActiveTime,
 		Public:            input.Public,
+		PublishAt:         input.PublishAt,
 		Servings:          input.Servings,
+		Nutrition:         input.Nutrition,
 		UserID:            user.ID,
 	}
 
 	// Validate data received.
 	v := validator.New()
+	if recipe.PublishAt != nil {
+		v.Check(recipe.PublishAt.After(time.Now()), "publish_at", "must be in the future")
+	}
 	if data.ValidateRecipe(v, recipe); !v.Valid() {
 		app.failedValidationResponse(w, r, v.Errors)
 		return
 	}
 
+	if !app.checkStorageQuota(w, r, user, 1, 0) {
+		return
+	}
+
 	// Call the Insert() method on our recipe model, passing in a pointer to the
 	// validated movie struct. This will create a record in the database and update the
 	// recipe struct with the system-generated information.
@@ -131,13 +152,30 @@ func (app *application) updateRecipeHandler(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
-	// Check if the authenticated user owns this recipe
+	// Check if the authenticated user owns this recipe, or is a collaborator with edit
+	// access
 	user := app.contextGetUser(r)
-	if recipe.UserID != user.ID {
+	if !recipe.EditableBy(user) {
 		app.notPermittedResponse(w, r)
 		return
 	}
 
+	// If the request contains a X-Expected-Version header, verify that the recipe
+	// version in the database matches the expected version. This stops collaborators
+	// editing a stale copy from silently overwriting each other's changes, so the header
+	// is required unless the user is the recipe's creator.
+	if r.Header.Get("X-Expected-Version") == "" && recipe.UserID != user.ID {
+		app.badRequestResponse(w, r, errors.New("the X-Expected-Version header must be provided when editing a recipe shared with you"))
+		return
+	}
+
+	if r.Header.Get("X-Expected-Version") != "" {
+		if strconv.FormatInt(int64(recipe.Version), 10) != r.Header.Get("X-Expected-Version") {
+			app.editConflictResponse(w, r)
+			return
+		}
+	}
+
 	// Parse the request body
 	var input struct {
 		Name              *string                `json:"name"`
@@ -150,7 +188,15 @@ func (app *application) updateRecipeHandler(w http.ResponseWriter, r *http.Reque
 		SourceURL         *string                `json:"source_url"`
 		PrepTime          *data.Duration         `json:"prep_time"`
 		ActiveTime        *data.Duration         `json:"active_time"`
+		Public            *bool                  `json:"public"`
+		PublishAt         json.RawMessage        `json:"publish_at"`
 		Servings          *int32                 `json:"servings"`
+		Nutrition         *struct {
+			Calories          *float64 `json:"calories"`
+			ProteinGrams      *float64 `json:"protein_grams"`
+			CarbohydrateGrams *float64 `json:"carbohydrate_grams"`
+			FatGrams          *float64 `json:"fat_grams"`
+		} `json:"nutrition"`
 	}
 
 	err = app.readJSON(w, r, &input)
@@ -159,6 +205,12 @@ func (app *application) updateRecipeHandler(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
+	// Only the creator can change who is able to see the recipe.
+	if (input.Public != nil || input.PublishAt != nil) && recipe.UserID != user.ID {
+		app.notPermittedResponse(w, r)
+		return
+	}
+
 	// Update fields if provided (partial update support)
 	if input.Name != nil {
 		recipe.Name = *input.Name
@@ -190,12 +242,57 @@ func (app *application) updateRecipeHandler(w http.ResponseWriter, r *http.Reque
 	if input.ActiveTime != nil {
 		recipe.ActiveTime = *input.ActiveTime
 	}
+	if input.Public != nil {
+		recipe.Public = *input.Public
+		// Publishing a draft straight away supersedes any schedule it had.
+		if recipe.Public {
+			recipe.PublishAt = nil
+		}
+	}
 	if input.Servings != nil {
 		recipe.Servings = *input.Servings
 	}
+	// Nutrition is partially updatable too, so that one figure can be corrected without
+	// resending the others. Figures left out of a recipe with no nutrition yet are zero.
+	if input.Nutrition != nil {
+		if recipe.Nutrition == nil {
+			recipe.Nutrition = &data.Nutrition{}
+		}
+		if input.Nutrition.Calories != nil {
+			recipe.Nutrition.Calories = *input.Nutrition.Calories
+		}
+		if input.Nutrition.ProteinGrams != nil {
+			recipe.Nutrition.ProteinGrams = *input.Nutrition.ProteinGrams
+		}
+		if input.Nutrition.CarbohydrateGrams != nil {
+			recipe.Nutrition.CarbohydrateGrams = *input.Nutrition.CarbohydrateGrams
+		}
+		if input.Nutrition.FatGrams != nil {
+			recipe.Nutrition.FatGrams = *input.Nutrition.FatGrams
+		}
+	}
 
 	// Validate the updated recipe
 	v := validator.New()
+
+	// A publish_at of null cancels the schedule, so it has to be distinguished from the
+	// field being left out.
+	if input.PublishAt != nil {
+		var publishAt *time.Time
+		if err := json.Unmarshal(input.PublishAt, &publishAt); err != nil {
+			v.AddError("publish_at", "must be an RFC 3339 timestamp or null")
+		} else if publishAt != nil {
+			v.Check(publishAt.After(time.Now()), "publish_at", "must be in the future")
+		}
+		recipe.PublishAt = publishAt
+	}
+
+	// A recipe unpublished by a moderator stays private until a moderator restores it.
+	if recipe.UnpublishedByModerator {
+		v.Check(!recipe.Public, "public", "cannot be set while the recipe is unpublished by a moderator")
+		v.Check(recipe.PublishAt == nil, "publish_at", "cannot be set while the recipe is unpublished by a moderator")
+	}
+
 	if data.ValidateRecipe(v, recipe); !v.Valid() {
 		app.failedValidationResponse(w, r, v.Errors)
 		return
@@ -266,12 +363,20 @@ func (app *application) deleteRecipeHandler(w http.ResponseWriter, r *http.Reque
 }
 
 func (app *application) listRecipesHandler(w http.ResponseWriter, r *http.Request) {
+	// A list of IDs asks for those specific recipes in full, rather than a search.
+	if r.URL.Query().Has("ids") {
+		app.batchGetRecipesHandler(w, r)
+		return
+	}
+
 	var input struct {
 		Name              string        `json:"name"`
 		Ingredients       []string      `json:"ingredients"`
 		RequiredEquipment []string      `json:"required_equipment"`
 		PrepTime          data.Duration `json:"prep_time"`
 		ActiveTime        data.Duration `json:"active_time"`
+		SuitableFor       int64         `json:"suitable_for"`
+		Scope             string        `json:"scope"`
 		data.Filters
 	}
 
@@ -285,6 +390,8 @@ func (app *application) listRecipesHandler(w http.ResponseWriter, r *http.Reques
 	// Query parameters accept minutes, convert to data.Duration
 	input.PrepTime = data.Duration(time.Duration(app.readInt(qs, "prep_time", 0, v)) * time.Minute)
 	input.ActiveTime = data.Duration(time.Duration(app.readInt(qs, "active_time", 0, v)) * time.Minute)
+	input.SuitableFor = int64(app.readInt(qs, "suitable_for", 0, v))
+	input.Scope = app.readString(qs, "scope", data.ScopeAll)
 	input.Filters.Page = app.readInt(qs, "page", 1, v)
 	input.Filters.PageSize = app.readInt(qs, "page_size", 20, v)
 
@@ -293,11 +400,36 @@ func (app *application) listRecipesHandler(w http.ResponseWriter, r *http.Reques
 	input.Sort = app.readString(qs, "sort", "id")
 	input.Filters.SortSafelist = []string{"id", "name", "prep_time", "active_time", "-id", "-name", "-prep_time", "-active_time"}
 
+	data.ValidateScope(v, input.Scope)
 	if data.ValidateFilters(v, input.Filters); !v.Valid() {
 		app.failedValidationResponse(w, r, v.Errors)
 		return
 	}
 
+	user := app.contextGetUser(r)
+
+	// Only authenticated users have a library of their own to search.
+	if input.Scope == data.ScopeMine && user.IsAnonymous() {
+		app.authenticationRequiredResponse(w, r)
+		return
+	}
+
+	// If a household member is given, exclude recipes containing anything they are
+	// allergic to or dislike. Only the user's own household members can be used.
+	excludedIngredients := []string{}
+	if input.SuitableFor != 0 {
+		member, err := app.models.Household.Get(input.SuitableFor)
+		switch {
+		case err == nil && !user.IsAnonymous() && member.UserID == user.ID:
+			excludedIngredients = member.ExcludedIngredients()
+		case err == nil || errors.Is(err, data.ErrRecordNotFound):
+			v.AddError("suitable_for", "must be one of your household members")
+		default:
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+	}
+
 	if !v.Valid() {
 		app.failedValidationResponse(w, r, v.Errors)
 		return
@@ -305,9 +437,12 @@ func (app *application) listRecipesHandler(w http.ResponseWriter, r *http.Reques
 
 	// Call the GetAll() method to retrieve the recipes
 	recipes, metadata, err := app.models.Recipes.GetAll(
+		user.ID,
+		input.Scope,
 		input.Name,
 		input.Ingredients,
 		input.RequiredEquipment,
+		excludedIngredients,
 		input.PrepTime,
 		input.ActiveTime,
 		input.Filters,
@@ -323,3 +458,53 @@ func (app *application) listRecipesHandler(w http.ResponseWriter, r *http.Reques
 		app.serverErrorResponse(w, r, err)
 	}
 }
+
+// The batchGetRecipesHandler() returns the full recipes listed in the ids query string
+// parameter, in the order given. Any IDs which don't exist or aren't visible to the user
+// are listed under "missing", rather than failing the whole request.
+func (app *application) batchGetRecipesHandler(w http.ResponseWriter, r *http.Request) {
+	v := validator.New()
+
+	ids := []int64{}
+	for _, s := range app.readCSV(r.URL.Query(), "ids", []string{}) {
+		id, err := strconv.ParseInt(strings.TrimSpace(s), 10, 64)
+		if err != nil || id < 1 {
+			v.AddError("ids", "must be a comma-separated list of recipe IDs")
+			break
+		}
+		ids = append(ids, id)
+	}
+
+	v.Check(len(ids) > 0, "ids", "must contain at least one ID")
+	v.Check(len(ids) <= data.MaxBatchRecipes, "ids", fmt.Sprintf("must not contain more than %d IDs", data.MaxBatchRecipes))
+	v.Check(validator.Unique(ids), "ids", "must not contain duplicate values")
+	v.Check(!r.URL.Query().Has("scope"), "scope", "cannot be combined with ids")
+
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	recipes, err := app.models.Recipes.GetMany(ids, app.contextGetUser(r).ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	found := make(map[int64]bool, len(recipes))
+	for _, recipe := range recipes {
+		found[recipe.ID] = true
+	}
+
+	missing := []int64{}
+	for _, id := range ids {
+		if !found[id] {
+			missing = append(missing, id)
+		}
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"recipes": recipes, "missing": missing}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
@@ -0,0 +1,254 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"eatinn.dcashman.net/internal/data"
+	"eatinn.dcashman.net/internal/validator"
+)
+
+func (app *application) createReportHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	var input struct {
+		Reason string `json:"reason"`
+	}
+
+	err = app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	user := app.contextGetUser(r)
+
+	// Only recipes which the user can actually see may be reported.
+	recipe, err := app.models.Recipes.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	if !recipe.VisibleTo(user) {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	report := &data.Report{
+		RecipeID: recipe.ID,
+		UserID:   user.ID,
+		Reason:   input.Reason,
+	}
+
+	// Moderation is for recipes shared publicly, so creators can't report their own
+	// recipes and collaborators can't report private ones shared with them.
+	v := validator.New()
+	v.Check(recipe.Public, "recipe", "only public recipes can be reported")
+	v.Check(recipe.UserID != user.ID, "recipe", "you cannot report your own recipe")
+
+	if data.ValidateReport(v, report); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.Reports.Insert(report)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrDuplicateReport):
+			v.AddError("recipe", "you have already reported this recipe")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	headers := make(http.Header)
+	headers.Set("Location", fmt.Sprintf("/v1/admin/reports/%d", report.ID))
+
+	err = app.writeJSON(w, http.StatusCreated, envelope{"report": report}, headers)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) listReportsHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Status   string
+		RecipeID int
+		data.Filters
+	}
+
+	v := validator.New()
+
+	qs := r.URL.Query()
+
+	input.Status = app.readString(qs, "status", data.ReportStatusOpen)
+	input.RecipeID = app.readInt(qs, "recipe_id", 0, v)
+	input.Filters.Page = app.readInt(qs, "page", 1, v)
+	input.Filters.PageSize = app.readInt(qs, "page_size", 20, v)
+
+	// Reports are always returned oldest first, so the moderation queue is worked
+	// through in the order it was filled.
+	input.Filters.Sort = "created_at"
+	input.Filters.SortSafelist = []string{"created_at"}
+
+	// An explicit "all" status disables the status filter.
+	if input.Status == "all" {
+		input.Status = ""
+	} else {
+		v.Check(validator.PermittedValue(input.Status, data.ReportStatusOpen, data.ReportStatusDismissed, data.ReportStatusActioned), "status", "invalid status value")
+	}
+	v.Check(input.RecipeID >= 0, "recipe_id", "must not be negative")
+
+	if data.ValidateFilters(v, input.Filters); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	reports, metadata, err := app.models.Reports.GetAll(input.Status, int64(input.RecipeID), input.Filters)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"reports": reports, "metadata": metadata}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) showReportHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	report, err := app.models.Reports.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	// Include the reported recipe so that a moderator can review it without having to
+	// make a second request (and regardless of whether it is still public).
+	recipe, err := app.models.Recipes.Get(report.RecipeID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"report": report, "recipe": recipe}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) dismissReportHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	report, err := app.models.Reports.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	v := validator.New()
+	if v.Check(report.Status == data.ReportStatusOpen, "status", "report has already been resolved"); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.Reports.Resolve(report, data.ReportStatusDismissed, app.contextGetUser(r).ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrEditConflict):
+			app.editConflictResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"report": report}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) unpublishRecipeHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	// Make the recipe private and close out every open report against it.
+	err = app.models.Reports.UnpublishRecipe(id, app.contextGetUser(r).ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"message": "recipe successfully unpublished"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// The restoreRecipeHandler() lifts a moderator's unpublishing of a recipe, so that its
+// creator can make it public again.
+func (app *application) restoreRecipeHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	err = app.models.Reports.RestoreRecipe(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"message": "recipe successfully restored"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
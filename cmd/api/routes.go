@@ -3,6 +3,8 @@ package main
 import (
 	"net/http"
 
+	"eatinn.dcashman.net/internal/data"
+
 	"github.com/julienschmidt/httprouter"
 )
 
@@ -23,17 +25,79 @@ func (app *application) routes() http.Handler {
 
 	// Recipes
 	router.HandlerFunc(http.MethodGet, "/v1/recipes", app.listRecipesHandler)
-	router.HandlerFunc(http.MethodPost, "/v1/recipes", app.requireActivatedUser(app.createRecipeHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/recipes", app.requireActivatedUser(app.idempotent(app.createRecipeHandler)))
 	router.HandlerFunc(http.MethodGet, "/v1/recipes/:id", app.showRecipeHandler)
 	router.HandlerFunc(http.MethodPatch, "/v1/recipes/:id", app.requireActivatedUser(app.updateRecipeHandler))
 	router.HandlerFunc(http.MethodDelete, "/v1/recipes/:id", app.requireActivatedUser(app.deleteRecipeHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/recipes/:id/report", app.requireActivatedUser(app.createReportHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/recipes/:id/steps/:n", app.showRecipeStepHandler)
+
+	// Recipe favorites
+	router.HandlerFunc(http.MethodPut, "/v1/recipes/:id/favorite", app.requireActivatedUser(app.favoriteRecipeHandler))
+	router.HandlerFunc(http.MethodDelete, "/v1/recipes/:id/favorite", app.requireActivatedUser(app.unfavoriteRecipeHandler))
+
+	// Recipe gallery
+	router.HandlerFunc(http.MethodPost, "/v1/recipes/:id/gallery", app.requireActivatedUser(app.addGalleryImageHandler))
+	router.HandlerFunc(http.MethodPut, "/v1/recipes/:id/gallery", app.requireActivatedUser(app.reorderGalleryHandler))
+	router.HandlerFunc(http.MethodPatch, "/v1/recipes/:id/gallery/:image_id", app.requireActivatedUser(app.updateGalleryImageHandler))
+	router.HandlerFunc(http.MethodDelete, "/v1/recipes/:id/gallery/:image_id", app.requireActivatedUser(app.deleteGalleryImageHandler))
+
+	// Recipe collaborators
+	router.HandlerFunc(http.MethodGet, "/v1/recipes/:id/collaborators", app.requireActivatedUser(app.listCollaboratorsHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/recipes/:id/collaborators", app.requireActivatedUser(app.addCollaboratorHandler))
+	router.HandlerFunc(http.MethodDelete, "/v1/recipes/:id/collaborators/:user_id", app.requireActivatedUser(app.revokeCollaboratorHandler))
+
+	// Recipe export and import (.eatinn files), and the images stored by imports
+	router.HandlerFunc(http.MethodGet, "/v1/recipes/:id/export", app.requireActivatedUser(app.exportRecipeHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/recipe-imports", app.requireActivatedUser(app.idempotent(app.importRecipeHandler)))
+	router.HandlerFunc(http.MethodGet, "/v1/images/:key", app.showImageHandler)
+
+	// Ingredients
+	router.HandlerFunc(http.MethodGet, "/v1/ingredients/:id/recipes", app.listIngredientRecipesHandler)
 
 	// Users
 	router.HandlerFunc(http.MethodPost, "/v1/users", app.registerUserHandler)
 	router.HandlerFunc(http.MethodPut, "/v1/users/activated", app.activateUserHandler)
+	router.HandlerFunc(http.MethodGet, "/v1/users/me/nutrition-goals", app.requireActivatedUser(app.showNutritionGoalsHandler))
+	router.HandlerFunc(http.MethodPatch, "/v1/users/me/nutrition-goals", app.requireActivatedUser(app.updateNutritionGoalsHandler))
+
+	// Meal plans
+	router.HandlerFunc(http.MethodGet, "/v1/meal-plan/entries", app.requireActivatedUser(app.listMealPlanEntriesHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/meal-plan/entries", app.requireActivatedUser(app.createMealPlanEntryHandler))
+	router.HandlerFunc(http.MethodDelete, "/v1/meal-plan/entries/:id", app.requireActivatedUser(app.deleteMealPlanEntryHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/meal-plan/nutrition", app.requireActivatedUser(app.showMealPlanNutritionHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/meal-plan/shopping-list", app.requireActivatedUser(app.showShoppingListHandler))
+
+	// Grocery stores
+	router.HandlerFunc(http.MethodGet, "/v1/grocery/providers", app.listGroceryProvidersHandler)
+	router.HandlerFunc(http.MethodPost, "/v1/grocery/matches", app.requireActivatedUser(app.matchGroceryProductsHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/grocery/carts", app.requireActivatedUser(app.exportGroceryCartHandler))
+
+	// Household member profiles
+	router.HandlerFunc(http.MethodGet, "/v1/household/members", app.requireActivatedUser(app.listHouseholdMembersHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/household/members", app.requireActivatedUser(app.createHouseholdMemberHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/household/members/:id", app.requireActivatedUser(app.showHouseholdMemberHandler))
+	router.HandlerFunc(http.MethodPatch, "/v1/household/members/:id", app.requireActivatedUser(app.updateHouseholdMemberHandler))
+	router.HandlerFunc(http.MethodDelete, "/v1/household/members/:id", app.requireActivatedUser(app.deleteHouseholdMemberHandler))
 
 	router.HandlerFunc(http.MethodPost, "/v1/tokens/authentication", app.createAuthenticationTokenHandler)
 
+	// Administration
+	router.HandlerFunc(http.MethodGet, "/v1/admin/config", app.requirePermission(data.PermissionAdmin, app.showConfigHandler))
+
+	// Rate limits and storage quotas
+	router.HandlerFunc(http.MethodGet, "/v1/admin/rate-limits", app.requirePermission(data.PermissionAdmin, app.listRateLimitsHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/admin/users/:id/limits", app.requirePermission(data.PermissionAdmin, app.showUserLimitsHandler))
+	router.HandlerFunc(http.MethodPut, "/v1/admin/users/:id/limits", app.requirePermission(data.PermissionAdmin, app.updateUserLimitsHandler))
+	router.HandlerFunc(http.MethodDelete, "/v1/admin/users/:id/limits", app.requirePermission(data.PermissionAdmin, app.deleteUserLimitsHandler))
+
+	// Moderation
+	router.HandlerFunc(http.MethodGet, "/v1/admin/reports", app.requirePermission(data.PermissionAdmin, app.listReportsHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/admin/reports/:id", app.requirePermission(data.PermissionAdmin, app.showReportHandler))
+	router.HandlerFunc(http.MethodPut, "/v1/admin/reports/:id/dismissed", app.requirePermission(data.PermissionAdmin, app.dismissReportHandler))
+	router.HandlerFunc(http.MethodPut, "/v1/admin/recipes/:id/unpublished", app.requirePermission(data.PermissionAdmin, app.unpublishRecipeHandler))
+	router.HandlerFunc(http.MethodDelete, "/v1/admin/recipes/:id/unpublished", app.requirePermission(data.PermissionAdmin, app.restoreRecipeHandler))
+
 	// Return the httprouter instance.
-	return app.recoverPanic(app.enableCORS(app.rateLimit(app.authenticate(router))))
+	return app.recoverPanic(app.enableCORS(app.rateLimit(app.authenticate(app.rateLimitUser(router)))))
 }
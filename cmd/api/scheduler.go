@@ -0,0 +1,41 @@
+package main
+
+import (
+	"time"
+)
+
+// The runPublishScheduler() method starts a background goroutine which publishes
+// scheduled recipes once their publish_at time has passed. It checks immediately, to
+// catch up on anything which fell due while the server was down, and then every
+// -publish-interval until the done channel is closed.
+func (app *application) runPublishScheduler(done <-chan struct{}) {
+	app.background(func() {
+		ticker := time.NewTicker(app.config.scheduler.publishInterval)
+		defer ticker.Stop()
+
+		for {
+			app.publishDueRecipes()
+
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+			}
+		}
+	})
+}
+
+// The publishDueRecipes() method makes a single pass over the scheduled recipes. Errors
+// are logged rather than returned, so that a failed pass is simply retried on the next
+// tick.
+func (app *application) publishDueRecipes() {
+	ids, err := app.models.Recipes.PublishDue()
+	if err != nil {
+		app.logger.Error("publishing scheduled recipes", "error", err.Error())
+		return
+	}
+
+	if len(ids) > 0 {
+		app.logger.Info("published scheduled recipes", "ids", ids)
+	}
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"crypto/tls"
 	"errors"
 	"fmt"
 	"log/slog"
@@ -10,6 +11,8 @@ import (
 	"os/signal"
 	"syscall"
 	"time"
+
+	"golang.org/x/crypto/acme/autocert"
 )
 
 func (app *application) serve() error {
@@ -22,8 +25,38 @@ func (app *application) serve() error {
 		ErrorLog:     slog.NewLogLogger(app.logger.Handler(), slog.LevelError),
 	}
 
+	// Serve both HTTP/1.1 and HTTP/2. Note that HTTP/2 is only negotiated over TLS
+	// connections, so plain HTTP clients will continue to use HTTP/1.1.
+	srv.Protocols = new(http.Protocols)
+	srv.Protocols.SetHTTP1(true)
+	srv.Protocols.SetHTTP2(true)
+
+	// If we're obtaining certificates automatically, hand the TLS configuration over
+	// to the autocert manager. It answers the TLS-ALPN-01 challenge itself, so the
+	// server must be reachable on port 443 for the configured domains.
+	if len(app.config.tls.autocertDomains) > 0 {
+		manager := &autocert.Manager{
+			Prompt:     autocert.AcceptTOS,
+			HostPolicy: autocert.HostWhitelist(app.config.tls.autocertDomains...),
+			Cache:      autocert.DirCache(app.config.tls.autocertCacheDir),
+		}
+
+		srv.TLSConfig = manager.TLSConfig()
+	} else {
+		srv.TLSConfig = &tls.Config{}
+	}
+
+	// Require TLS 1.2 or later, using only the X25519 and P-256 key exchanges.
+	srv.TLSConfig.MinVersion = tls.VersionTLS12
+	srv.TLSConfig.CurvePreferences = []tls.CurveID{tls.X25519, tls.CurveP256}
+
 	shutdownError := make(chan error)
 
+	// Start publishing scheduled recipes. Closing schedulerDone stops the scheduler,
+	// which the graceful shutdown then waits for along with other background tasks.
+	schedulerDone := make(chan struct{})
+	app.runPublishScheduler(schedulerDone)
+
 	// Background goroutine to handle graceful shutdowns.
 	go func() {
 		quit := make(chan os.Signal, 1)
@@ -34,6 +67,8 @@ func (app *application) serve() error {
 
 		app.logger.Info("shutting down server", "signal", s.String())
 
+		close(schedulerDone)
+
 		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 		defer cancel()
 
@@ -61,13 +96,20 @@ func (app *application) serve() error {
 	}()
 
 	// Likewise log a "starting server" message.
-	app.logger.Info("starting server", "addr", srv.Addr, "env", app.config.env)
+	app.logger.Info("starting server", "addr", srv.Addr, "env", app.config.env, "tls", app.tlsEnabled())
 
 	// Calling Shutdown() on our server will cause ListenAndServe() to immediately
 	// return a http.ErrServerClosed error. So if we see this error, it is actually a
 	// good thing and an indication that the graceful shutdown has started. So we check
 	// specifically for this, only returning the error if it is NOT http.ErrServerClosed.
-	err := srv.ListenAndServe()
+	// When TLS is enabled the certificate comes either from the configured files or,
+	// if they are empty, from the autocert manager's GetCertificate hook.
+	var err error
+	if app.tlsEnabled() {
+		err = srv.ListenAndServeTLS(app.config.tls.certFile, app.config.tls.keyFile)
+	} else {
+		err = srv.ListenAndServe()
+	}
 	if !errors.Is(err, http.ErrServerClosed) {
 		return err
 	}
@@ -86,3 +128,9 @@ func (app *application) serve() error {
 
 	return nil
 }
+
+// The tlsEnabled() helper reports whether the server should serve HTTPS, either from
+// a certificate/key pair on disk or via Let's Encrypt.
+func (app *application) tlsEnabled() bool {
+	return app.config.tls.certFile != "" || len(app.config.tls.autocertDomains) > 0
+}
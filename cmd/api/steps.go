@@ -0,0 +1,97 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"net/http"
+	"strings"
+
+	"eatinn.dcashman.net/internal/data"
+)
+
+// The showRecipeStepHandler() returns a single step of a recipe. Kitchen displays poll
+// it, so responses carry an ETag derived from the recipe's version, and a request whose
+// If-None-Match header matches gets a 304 Not Modified without the step being read.
+func (app *application) showRecipeStepHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	n, err := app.readNamedIDParam(r, "n")
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	recipe, err := app.models.Recipes.GetStepRecipe(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	if !recipe.VisibleTo(app.contextGetUser(r)) {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	// Every change to a recipe increments its version, so the version and step number
+	// identify the content of the response. Whether the user may see it is checked
+	// above on every request, so caches must revalidate each time.
+	headers := make(http.Header)
+	headers.Set("ETag", fmt.Sprintf(`"%d-%d-%d"`, recipe.ID, recipe.Version, n))
+	headers.Set("Cache-Control", "private, no-cache")
+
+	if etagMatches(r.Header.Get("If-None-Match"), headers.Get("ETag")) {
+		maps.Copy(w.Header(), headers)
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	step, err := app.models.Recipes.GetKitchenStep(recipe, int(n))
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	// Links to the neighbouring steps are null at either end of the recipe.
+	links := map[string]*string{"prev": nil, "next": nil}
+	if step.Number > 1 {
+		prev := fmt.Sprintf("/v1/recipes/%d/steps/%d", recipe.ID, step.Number-1)
+		links["prev"] = &prev
+	}
+	if step.Number < step.TotalSteps {
+		next := fmt.Sprintf("/v1/recipes/%d/steps/%d", recipe.ID, step.Number+1)
+		links["next"] = &next
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"step": step, "links": links}, headers)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// The etagMatches() helper reports whether an If-None-Match header value, which may be
+// a comma-separated list of weak or strong entity tags or "*", matches the ETag.
+func etagMatches(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == etag || candidate == "*" {
+			return true
+		}
+	}
+
+	return false
+}
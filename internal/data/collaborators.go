@@ -0,0 +1,164 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"eatinn.dcashman.net/internal/validator"
+	"github.com/lib/pq"
+)
+
+// Access levels which can be granted to a recipe's collaborators.
+const (
+	CollaboratorRead = "read"
+	CollaboratorEdit = "edit"
+)
+
+// Collaborator is a user who has been granted access to someone else's recipe.
+type Collaborator struct {
+	RecipeID  int64     `json:"-"`
+	UserID    int64     `json:"user_id"`
+	Name      string    `json:"name"`
+	Email     string    `json:"email"`
+	Access    string    `json:"access"`
+	GrantedBy int64     `json:"granted_by,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func ValidateCollaborator(v *validator.Validator, collaborator *Collaborator) {
+	v.Check(validator.PermittedValue(collaborator.Access, CollaboratorRead, CollaboratorEdit), "access", "must be read or edit")
+}
+
+// Define a CollaboratorModel struct type which wraps a sql.DB connection pool.
+type CollaboratorModel struct {
+	DB *sql.DB
+}
+
+// Upsert grants a user access to a recipe, replacing any access they already had.
+func (m CollaboratorModel) Upsert(collaborator *Collaborator) error {
+	query := `
+		INSERT INTO recipe_collaborators (recipe_id, user_id, access, granted_by)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (recipe_id, user_id) DO UPDATE
+		SET access = EXCLUDED.access, granted_by = EXCLUDED.granted_by
+		RETURNING created_at`
+
+	args := []any{collaborator.RecipeID, collaborator.UserID, collaborator.Access, collaborator.GrantedBy}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	return m.DB.QueryRowContext(ctx, query, args...).Scan(&collaborator.CreatedAt)
+}
+
+// GetAllForRecipe returns a recipe's collaborators, in the order they were added.
+func (m CollaboratorModel) GetAllForRecipe(recipeID int64) ([]*Collaborator, error) {
+	query := `
+		SELECT c.recipe_id, c.user_id, u.name, u.email, c.access, COALESCE(c.granted_by, 0), c.created_at
+		FROM recipe_collaborators c
+		INNER JOIN users u ON u.id = c.user_id
+		WHERE c.recipe_id = $1
+		ORDER BY c.created_at, c.user_id`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, recipeID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	collaborators := []*Collaborator{}
+
+	for rows.Next() {
+		var collaborator Collaborator
+
+		err := rows.Scan(
+			&collaborator.RecipeID,
+			&collaborator.UserID,
+			&collaborator.Name,
+			&collaborator.Email,
+			&collaborator.Access,
+			&collaborator.GrantedBy,
+			&collaborator.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		collaborators = append(collaborators, &collaborator)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return collaborators, nil
+}
+
+// Delete revokes a user's access to a recipe.
+func (m CollaboratorModel) Delete(recipeID, userID int64) error {
+	query := `
+		DELETE FROM recipe_collaborators
+		WHERE recipe_id = $1 AND user_id = $2`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, recipeID, userID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
+
+// getCollaboratorAccess fetches the access granted to each collaborator on the given
+// recipes, keyed by recipe ID and then user ID. It is used by RecipeModel so that the
+// access checks on Recipe can take collaborators into account.
+func getCollaboratorAccess(ctx context.Context, db *sql.DB, recipeIDs []int64) (map[int64]map[int64]string, error) {
+	query := `
+		SELECT recipe_id, user_id, access
+		FROM recipe_collaborators
+		WHERE recipe_id = ANY($1)`
+
+	rows, err := db.QueryContext(ctx, query, pq.Array(recipeIDs))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	access := make(map[int64]map[int64]string)
+
+	for rows.Next() {
+		var recipeID, userID int64
+		var level string
+
+		err := rows.Scan(&recipeID, &userID, &level)
+		if err != nil {
+			return nil, err
+		}
+
+		if access[recipeID] == nil {
+			access[recipeID] = make(map[int64]string)
+		}
+		access[recipeID][userID] = level
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return access, nil
+}
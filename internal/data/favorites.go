@@ -0,0 +1,66 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"eatinn.dcashman.net/internal/validator"
+)
+
+// Recipe list scopes. ScopeMine restricts a list to the recipes the viewer created or
+// favorited.
+const (
+	ScopeAll  = "all"
+	ScopeMine = "mine"
+)
+
+func ValidateScope(v *validator.Validator, scope string) {
+	v.Check(validator.PermittedValue(scope, ScopeAll, ScopeMine), "scope", "must be all or mine")
+}
+
+// Define a FavoriteModel struct type which wraps a sql.DB connection pool.
+type FavoriteModel struct {
+	DB *sql.DB
+}
+
+// Insert adds a recipe to the user's favorites. Favoriting a recipe twice is not an
+// error.
+func (m FavoriteModel) Insert(userID, recipeID int64) error {
+	query := `
+		INSERT INTO recipe_favorites (user_id, recipe_id)
+		VALUES ($1, $2)
+		ON CONFLICT DO NOTHING`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := m.DB.ExecContext(ctx, query, userID, recipeID)
+	return err
+}
+
+// Delete removes a recipe from the user's favorites.
+func (m FavoriteModel) Delete(userID, recipeID int64) error {
+	query := `
+		DELETE FROM recipe_favorites
+		WHERE user_id = $1 AND recipe_id = $2`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, userID, recipeID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
@@ -0,0 +1,287 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"eatinn.dcashman.net/internal/validator"
+	"github.com/lib/pq"
+)
+
+// MaxGalleryImages is the maximum number of gallery photos a single recipe may have.
+const MaxGalleryImages = 50
+
+// GalleryImage is a user-ordered, optionally captioned photo in a recipe's gallery.
+// Gallery photos are stored in recipe_images alongside the main and step images.
+type GalleryImage struct {
+	ID         int64     `json:"id"`
+	RecipeID   int64     `json:"-"`
+	URL        string    `json:"url"`
+	Caption    string    `json:"caption,omitempty"`
+	Position   int32     `json:"position"`
+	UploadedAt time.Time `json:"uploaded_at"`
+}
+
+func ValidateGalleryImage(v *validator.Validator, image *GalleryImage) {
+	v.Check(image.URL != "", "url", "must be provided")
+	v.Check(len(image.URL) <= 2048, "url", "must not be more than 2048 bytes long")
+	v.Check(len(image.Caption) <= 500, "caption", "must not be more than 500 bytes long")
+}
+
+// Define a GalleryModel struct type which wraps a sql.DB connection pool.
+type GalleryModel struct {
+	DB *sql.DB
+}
+
+// Insert appends a photo to the end of a recipe's gallery. The recipe's row is locked
+// first, so that photos added at the same time are given different positions.
+func (m GalleryModel) Insert(image *GalleryImage) error {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err = lockRecipe(ctx, tx, image.RecipeID)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO recipe_images (recipe_id, image_url, image_type, caption, position)
+		SELECT $1, $2, 'gallery', $3, COALESCE(MAX(position), 0) + 1
+		FROM recipe_images
+		WHERE recipe_id = $1 AND image_type = 'gallery'
+		RETURNING id, position, uploaded_at`
+
+	args := []any{image.RecipeID, image.URL, nilIfZero(image.Caption)}
+
+	err = tx.QueryRowContext(ctx, query, args...).Scan(&image.ID, &image.Position, &image.UploadedAt)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Get fetches a specific gallery photo belonging to a recipe.
+func (m GalleryModel) Get(recipeID, id int64) (*GalleryImage, error) {
+	if id < 1 {
+		return nil, ErrRecordNotFound
+	}
+
+	query := `
+		SELECT id, recipe_id, image_url, caption, position, uploaded_at
+		FROM recipe_images
+		WHERE id = $1 AND recipe_id = $2 AND image_type = 'gallery'`
+
+	var image GalleryImage
+	var caption sql.NullString
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, id, recipeID).Scan(
+		&image.ID,
+		&image.RecipeID,
+		&image.URL,
+		&caption,
+		&image.Position,
+		&image.UploadedAt,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	image.Caption = caption.String
+
+	return &image, nil
+}
+
+// GetAllForRecipe returns a recipe's gallery in display order.
+func (m GalleryModel) GetAllForRecipe(recipeID int64) ([]GalleryImage, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	return getGallery(ctx, m.DB, recipeID)
+}
+
+// UpdateCaption changes the caption of a gallery photo.
+func (m GalleryModel) UpdateCaption(image *GalleryImage) error {
+	query := `
+		UPDATE recipe_images
+		SET caption = $1
+		WHERE id = $2 AND recipe_id = $3 AND image_type = 'gallery'`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, nilIfZero(image.Caption), image.ID, image.RecipeID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
+
+// Delete removes a photo from a recipe's gallery. The remaining photos keep their
+// relative order.
+func (m GalleryModel) Delete(recipeID, id int64) error {
+	if id < 1 {
+		return ErrRecordNotFound
+	}
+
+	query := `
+		DELETE FROM recipe_images
+		WHERE id = $1 AND recipe_id = $2 AND image_type = 'gallery'`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, id, recipeID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
+
+// Reorder sets the gallery order to match imageIDs, which must contain every photo in
+// the recipe's gallery exactly once. If the gallery has changed in the meantime (so
+// that the IDs no longer match) ErrEditConflict is returned.
+func (m GalleryModel) Reorder(recipeID int64, imageIDs []int64) error {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err = lockRecipe(ctx, tx, recipeID)
+	if err != nil {
+		return err
+	}
+
+	var count int
+	err = tx.QueryRowContext(ctx, `
+		SELECT COUNT(*)
+		FROM recipe_images
+		WHERE recipe_id = $1 AND image_type = 'gallery'
+	`, recipeID).Scan(&count)
+	if err != nil {
+		return err
+	}
+
+	result, err := tx.ExecContext(ctx, `
+		UPDATE recipe_images
+		SET position = o.position
+		FROM unnest($2::bigint[]) WITH ORDINALITY AS o(id, position)
+		WHERE recipe_images.id = o.id AND recipe_images.recipe_id = $1 AND recipe_images.image_type = 'gallery'
+	`, recipeID, pq.Array(imageIDs))
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if int(rowsAffected) != count || len(imageIDs) != count {
+		return ErrEditConflict
+	}
+
+	return tx.Commit()
+}
+
+// lockRecipe locks the recipe's row until the end of the transaction, so that changes
+// to its gallery are made one at a time. It returns ErrRecordNotFound if the recipe
+// doesn't exist.
+func lockRecipe(ctx context.Context, tx *sql.Tx, recipeID int64) error {
+	var id int64
+	err := tx.QueryRowContext(ctx, `SELECT id FROM recipes WHERE id = $1 FOR UPDATE`, recipeID).Scan(&id)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrRecordNotFound
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+// getGallery fetches a recipe's gallery. It is shared with RecipeModel.Get() so that
+// the gallery can be included in recipe responses.
+func getGallery(ctx context.Context, db *sql.DB, recipeID int64) ([]GalleryImage, error) {
+	query := `
+		SELECT id, recipe_id, image_url, caption, position, uploaded_at
+		FROM recipe_images
+		WHERE recipe_id = $1 AND image_type = 'gallery'
+		ORDER BY position, id`
+
+	rows, err := db.QueryContext(ctx, query, recipeID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	gallery := []GalleryImage{}
+
+	for rows.Next() {
+		var image GalleryImage
+		var caption sql.NullString
+
+		err := rows.Scan(
+			&image.ID,
+			&image.RecipeID,
+			&image.URL,
+			&caption,
+			&image.Position,
+			&image.UploadedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		image.Caption = caption.String
+		gallery = append(gallery, image)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return gallery, nil
+}
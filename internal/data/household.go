@@ -0,0 +1,229 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"slices"
+	"strings"
+	"time"
+
+	"eatinn.dcashman.net/internal/validator"
+	"github.com/lib/pq"
+)
+
+// HouseholdMember is a profile for someone the user cooks for, such as a child, with
+// the ingredients they are allergic to or dislike.
+type HouseholdMember struct {
+	ID        int64     `json:"id"`
+	CreatedAt time.Time `json:"-"`
+	UserID    int64     `json:"-"`
+	Name      string    `json:"name"`
+	Allergies []string  `json:"allergies"`
+	Dislikes  []string  `json:"dislikes"`
+	Version   int32     `json:"version"`
+}
+
+// ExcludedIngredients returns every ingredient that recipes suitable for the member
+// must not contain. Blank entries are skipped, since they would match every recipe.
+func (m *HouseholdMember) ExcludedIngredients() []string {
+	excluded := make([]string, 0, len(m.Allergies)+len(m.Dislikes))
+	for _, ingredient := range slices.Concat(m.Allergies, m.Dislikes) {
+		if strings.TrimSpace(ingredient) != "" {
+			excluded = append(excluded, ingredient)
+		}
+	}
+	return excluded
+}
+
+// TrimIngredients removes leading and trailing whitespace from the member's allergies
+// and dislikes. It should be called before the member is validated.
+func (m *HouseholdMember) TrimIngredients() {
+	for i := range m.Allergies {
+		m.Allergies[i] = strings.TrimSpace(m.Allergies[i])
+	}
+	for i := range m.Dislikes {
+		m.Dislikes[i] = strings.TrimSpace(m.Dislikes[i])
+	}
+}
+
+func ValidateHouseholdMember(v *validator.Validator, member *HouseholdMember) {
+	v.Check(member.Name != "", "name", "must be provided")
+	v.Check(len(member.Name) <= 500, "name", "must not be more than 500 bytes long")
+
+	v.Check(len(member.Allergies) <= 50, "allergies", "must not contain more than 50 ingredients")
+	v.Check(validator.Unique(member.Allergies), "allergies", "must not contain duplicate values")
+	for _, ingredient := range member.Allergies {
+		v.Check(strings.TrimSpace(ingredient) != "", "allergies", "must not contain blank values")
+	}
+
+	v.Check(len(member.Dislikes) <= 50, "dislikes", "must not contain more than 50 ingredients")
+	v.Check(validator.Unique(member.Dislikes), "dislikes", "must not contain duplicate values")
+	for _, ingredient := range member.Dislikes {
+		v.Check(strings.TrimSpace(ingredient) != "", "dislikes", "must not contain blank values")
+	}
+}
+
+// Define a HouseholdMemberModel struct type which wraps a sql.DB connection pool.
+type HouseholdMemberModel struct {
+	DB *sql.DB
+}
+
+// Insert adds a new household member profile.
+func (m HouseholdMemberModel) Insert(member *HouseholdMember) error {
+	query := `
+		INSERT INTO household_members (user_id, name, allergies, dislikes)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, created_at, version`
+
+	args := []any{member.UserID, member.Name, pq.Array(member.Allergies), pq.Array(member.Dislikes)}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	return m.DB.QueryRowContext(ctx, query, args...).Scan(&member.ID, &member.CreatedAt, &member.Version)
+}
+
+// Get fetches a specific household member by ID.
+func (m HouseholdMemberModel) Get(id int64) (*HouseholdMember, error) {
+	if id < 1 {
+		return nil, ErrRecordNotFound
+	}
+
+	query := `
+		SELECT id, created_at, user_id, name, allergies, dislikes, version
+		FROM household_members
+		WHERE id = $1`
+
+	var member HouseholdMember
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, id).Scan(
+		&member.ID,
+		&member.CreatedAt,
+		&member.UserID,
+		&member.Name,
+		pq.Array(&member.Allergies),
+		pq.Array(&member.Dislikes),
+		&member.Version,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &member, nil
+}
+
+// GetAllForUser returns all of a user's household members, in the order they were
+// added.
+func (m HouseholdMemberModel) GetAllForUser(userID int64) ([]*HouseholdMember, error) {
+	query := `
+		SELECT id, created_at, user_id, name, allergies, dislikes, version
+		FROM household_members
+		WHERE user_id = $1
+		ORDER BY id`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	members := []*HouseholdMember{}
+
+	for rows.Next() {
+		var member HouseholdMember
+
+		err := rows.Scan(
+			&member.ID,
+			&member.CreatedAt,
+			&member.UserID,
+			&member.Name,
+			pq.Array(&member.Allergies),
+			pq.Array(&member.Dislikes),
+			&member.Version,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		members = append(members, &member)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return members, nil
+}
+
+// Update modifies a household member profile, using the version field for optimistic
+// locking.
+func (m HouseholdMemberModel) Update(member *HouseholdMember) error {
+	query := `
+		UPDATE household_members
+		SET name = $1, allergies = $2, dislikes = $3, version = version + 1
+		WHERE id = $4 AND version = $5
+		RETURNING version`
+
+	args := []any{
+		member.Name,
+		pq.Array(member.Allergies),
+		pq.Array(member.Dislikes),
+		member.ID,
+		member.Version,
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&member.Version)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrEditConflict
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Delete removes a household member profile.
+func (m HouseholdMemberModel) Delete(id int64) error {
+	if id < 1 {
+		return ErrRecordNotFound
+	}
+
+	query := `DELETE FROM household_members WHERE id = $1`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, id)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
@@ -0,0 +1,135 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"eatinn.dcashman.net/internal/validator"
+)
+
+// IdempotencyKeyTTL is how long a stored response is replayed for a given key. After
+// this the key may be reused for a new request.
+const IdempotencyKeyTTL = 24 * time.Hour
+
+// IdempotencyKey holds the fingerprint of a request made with an Idempotency-Key
+// header, along with the response that was sent for it. StatusCode is zero while the
+// original request is still being processed.
+type IdempotencyKey struct {
+	UserID          int64
+	Key             string
+	CreatedAt       time.Time
+	RequestHash     []byte
+	StatusCode      int
+	ResponseHeaders http.Header
+	ResponseBody    []byte
+}
+
+func ValidateIdempotencyKey(v *validator.Validator, key string) {
+	v.Check(key != "", "Idempotency-Key", "must be provided")
+	v.Check(len(key) <= 255, "Idempotency-Key", "must not be more than 255 bytes long")
+}
+
+// Define the IdempotencyModel type.
+type IdempotencyModel struct {
+	DB *sql.DB
+}
+
+// Reserve claims the key for a new request. If the key is unused (or has expired) a new
+// in-progress record is created and returned along with true. Otherwise the existing
+// record is returned along with false, so that the caller can replay or reject it.
+func (m IdempotencyModel) Reserve(userID int64, key string, requestHash []byte) (*IdempotencyKey, bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	// Expired keys for the user are cleaned up opportunistically, which also frees up
+	// the key being reserved if it has expired.
+	_, err := m.DB.ExecContext(ctx, `
+		DELETE FROM idempotency_keys
+		WHERE user_id = $1 AND created_at < $2
+	`, userID, time.Now().Add(-IdempotencyKeyTTL))
+	if err != nil {
+		return nil, false, err
+	}
+
+	record := &IdempotencyKey{
+		UserID:      userID,
+		Key:         key,
+		RequestHash: requestHash,
+	}
+
+	err = m.DB.QueryRowContext(ctx, `
+		INSERT INTO idempotency_keys (user_id, key, request_hash)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (user_id, key) DO NOTHING
+		RETURNING created_at
+	`, userID, key, requestHash).Scan(&record.CreatedAt)
+	if err == nil {
+		return record, true, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return nil, false, err
+	}
+
+	// The key is already in use, so fetch the existing record instead.
+	var statusCode sql.NullInt32
+	var headers, body []byte
+
+	err = m.DB.QueryRowContext(ctx, `
+		SELECT created_at, request_hash, status_code, response_headers, response_body
+		FROM idempotency_keys
+		WHERE user_id = $1 AND key = $2
+	`, userID, key).Scan(&record.CreatedAt, &record.RequestHash, &statusCode, &headers, &body)
+	if err != nil {
+		return nil, false, err
+	}
+
+	if statusCode.Valid {
+		record.StatusCode = int(statusCode.Int32)
+		record.ResponseBody = body
+
+		err = json.Unmarshal(headers, &record.ResponseHeaders)
+		if err != nil {
+			return nil, false, err
+		}
+	}
+
+	return record, false, nil
+}
+
+// Complete stores the response sent for a reserved key, so that it can be replayed.
+func (m IdempotencyModel) Complete(record *IdempotencyKey) error {
+	headers, err := json.Marshal(record.ResponseHeaders)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		UPDATE idempotency_keys
+		SET status_code = $1, response_headers = $2, response_body = $3
+		WHERE user_id = $4 AND key = $5`
+
+	args := []any{record.StatusCode, headers, record.ResponseBody, record.UserID, record.Key}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err = m.DB.ExecContext(ctx, query, args...)
+	return err
+}
+
+// Delete releases a reserved key, allowing the request to be retried.
+func (m IdempotencyModel) Delete(userID int64, key string) error {
+	query := `
+		DELETE FROM idempotency_keys
+		WHERE user_id = $1 AND key = $2`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := m.DB.ExecContext(ctx, query, userID, key)
+	return err
+}
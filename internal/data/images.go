@@ -0,0 +1,112 @@
+package data
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/base32"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// MaxImageBytes is the largest image which can be stored.
+const MaxImageBytes = 5 * 1_048_576
+
+// ImageContentTypeSafelist holds the image formats which can be stored.
+var ImageContentTypeSafelist = []string{"image/jpeg", "image/png", "image/gif", "image/webp"}
+
+// StoredImage is an image hosted by eatinn itself, rather than linked from elsewhere. It
+// is addressed by a random key so that images belonging to private recipes can't be
+// found by guessing.
+type StoredImage struct {
+	Key         string
+	CreatedAt   time.Time
+	UserID      int64
+	ContentType string
+	Data        []byte
+}
+
+// URL returns the path at which the image is served.
+func (i *StoredImage) URL() string {
+	return fmt.Sprintf("/v1/images/%s", i.Key)
+}
+
+// Define an ImageModel struct type which wraps a sql.DB connection pool.
+type ImageModel struct {
+	DB *sql.DB
+}
+
+// NewImageKey generates a random key for a new stored image.
+func NewImageKey() (string, error) {
+	randomBytes := make([]byte, 16)
+
+	_, err := rand.Read(randomBytes)
+	if err != nil {
+		return "", err
+	}
+
+	return base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(randomBytes), nil
+}
+
+// Get fetches a stored image by its key.
+func (m ImageModel) Get(key string) (*StoredImage, error) {
+	query := `
+		SELECT key, created_at, user_id, content_type, data
+		FROM images
+		WHERE key = $1`
+
+	var image StoredImage
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, key).Scan(
+		&image.Key,
+		&image.CreatedAt,
+		&image.UserID,
+		&image.ContentType,
+		&image.Data,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &image, nil
+}
+
+// VisibleTo reports whether the stored image may be shown to viewerID: either they
+// uploaded it, or it is used by a recipe they can see. Images are referenced from
+// recipes by their URL.
+func (m ImageModel) VisibleTo(image *StoredImage, viewerID int64) (bool, error) {
+	if viewerID != 0 && image.UserID == viewerID {
+		return true, nil
+	}
+
+	query := `
+		SELECT EXISTS (
+			SELECT 1
+			FROM recipe_images ri
+			INNER JOIN recipes r ON r.id = ri.recipe_id
+			WHERE ri.image_url = $1
+			  AND (r.public = TRUE OR r.user_id = $2 OR EXISTS (
+				SELECT 1 FROM recipe_collaborators rc
+				WHERE rc.recipe_id = r.id AND rc.user_id = $2)))`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var visible bool
+
+	err := m.DB.QueryRowContext(ctx, query, image.URL(), viewerID).Scan(&visible)
+	if err != nil {
+		return false, err
+	}
+
+	return visible, nil
+}
@@ -0,0 +1,56 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// Ingredient is a normalized ingredient name, shared between all the recipes which
+// use it.
+type Ingredient struct {
+	ID        int64     `json:"id"`
+	CreatedAt time.Time `json:"-"`
+	Name      string    `json:"name"`
+	Version   int32     `json:"-"`
+}
+
+// Define an IngredientModel struct type which wraps a sql.DB connection pool.
+type IngredientModel struct {
+	DB *sql.DB
+}
+
+// Get fetches a specific ingredient by ID.
+func (m IngredientModel) Get(id int64) (*Ingredient, error) {
+	if id < 1 {
+		return nil, ErrRecordNotFound
+	}
+
+	query := `
+		SELECT id, created_at, name, version
+		FROM ingredients
+		WHERE id = $1`
+
+	var ingredient Ingredient
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, id).Scan(
+		&ingredient.ID,
+		&ingredient.CreatedAt,
+		&ingredient.Name,
+		&ingredient.Version,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &ingredient, nil
+}
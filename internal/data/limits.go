@@ -0,0 +1,223 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"eatinn.dcashman.net/internal/validator"
+)
+
+// UserLimits holds an administrator's overrides of the rate limit and storage quotas
+// for a single user. A nil field means that the server-wide default applies. A quota of
+// 0 blocks the user from storing anything more, while the Unlimited fields exempt them
+// from the quota altogether.
+type UserLimits struct {
+	UserID              int64    `json:"user_id"`
+	RPS                 *float64 `json:"rps"`
+	Burst               *int     `json:"burst"`
+	MaxRecipes          *int64   `json:"max_recipes"`
+	UnlimitedRecipes    bool     `json:"unlimited_recipes"`
+	MaxImageBytes       *int64   `json:"max_image_bytes"`
+	UnlimitedImageBytes bool     `json:"unlimited_image_bytes"`
+	Version             int32    `json:"version"`
+}
+
+// StorageUsage is how much of their storage quotas a user has used.
+type StorageUsage struct {
+	Recipes    int64 `json:"recipes"`
+	ImageBytes int64 `json:"image_bytes"`
+}
+
+// ValidateUserLimits checks the overrides against the server-wide rate limit. Every
+// request is also counted against the limit for its IP address, which uses the
+// defaults, so a rate or burst above them would have no effect.
+func ValidateUserLimits(v *validator.Validator, limits *UserLimits, defaultRPS float64, defaultBurst int) {
+	if limits.RPS != nil {
+		v.Check(*limits.RPS > 0, "rps", "must be greater than zero")
+		v.Check(*limits.RPS <= defaultRPS, "rps", fmt.Sprintf("must not be more than the default of %g", defaultRPS))
+	}
+	if limits.Burst != nil {
+		v.Check(*limits.Burst > 0, "burst", "must be greater than zero")
+		v.Check(*limits.Burst <= defaultBurst, "burst", fmt.Sprintf("must not be more than the default of %d", defaultBurst))
+	}
+	if limits.MaxRecipes != nil {
+		v.Check(*limits.MaxRecipes >= 0, "max_recipes", "must not be negative")
+		v.Check(!limits.UnlimitedRecipes, "max_recipes", "must not be provided when unlimited_recipes is true")
+	}
+	if limits.MaxImageBytes != nil {
+		v.Check(*limits.MaxImageBytes >= 0, "max_image_bytes", "must not be negative")
+		v.Check(!limits.UnlimitedImageBytes, "max_image_bytes", "must not be provided when unlimited_image_bytes is true")
+	}
+}
+
+// Define a LimitModel struct type which wraps a sql.DB connection pool.
+type LimitModel struct {
+	DB *sql.DB
+}
+
+// GetForUser fetches the user's overrides. Users without any get a zero value (with
+// version 0), rather than an error.
+func (m LimitModel) GetForUser(userID int64) (*UserLimits, error) {
+	query := `
+		SELECT rps, burst, max_recipes, unlimited_recipes, max_image_bytes, unlimited_image_bytes, version
+		FROM user_limits
+		WHERE user_id = $1`
+
+	limits := UserLimits{UserID: userID}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, userID).Scan(
+		&limits.RPS,
+		&limits.Burst,
+		&limits.MaxRecipes,
+		&limits.UnlimitedRecipes,
+		&limits.MaxImageBytes,
+		&limits.UnlimitedImageBytes,
+		&limits.Version,
+	)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+
+	return &limits, nil
+}
+
+// GetAll returns every user's overrides, so that the rate limiter can be primed with
+// them when the server starts.
+func (m LimitModel) GetAll() ([]*UserLimits, error) {
+	query := `
+		SELECT user_id, rps, burst, max_recipes, unlimited_recipes, max_image_bytes, unlimited_image_bytes, version
+		FROM user_limits
+		ORDER BY user_id`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	all := []*UserLimits{}
+
+	for rows.Next() {
+		var limits UserLimits
+
+		err := rows.Scan(
+			&limits.UserID,
+			&limits.RPS,
+			&limits.Burst,
+			&limits.MaxRecipes,
+			&limits.UnlimitedRecipes,
+			&limits.MaxImageBytes,
+			&limits.UnlimitedImageBytes,
+			&limits.Version,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		all = append(all, &limits)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return all, nil
+}
+
+// Upsert creates or replaces the user's overrides, using the version field for
+// optimistic locking. A version of 0 means that the overrides are being created for the
+// first time.
+func (m LimitModel) Upsert(limits *UserLimits) error {
+	query := `
+		INSERT INTO user_limits (user_id, rps, burst, max_recipes, unlimited_recipes, max_image_bytes, unlimited_image_bytes)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT (user_id) DO UPDATE
+		SET rps = EXCLUDED.rps, burst = EXCLUDED.burst,
+		    max_recipes = EXCLUDED.max_recipes, unlimited_recipes = EXCLUDED.unlimited_recipes,
+		    max_image_bytes = EXCLUDED.max_image_bytes, unlimited_image_bytes = EXCLUDED.unlimited_image_bytes,
+		    version = user_limits.version + 1
+		WHERE user_limits.version = $8
+		RETURNING version`
+
+	args := []any{
+		limits.UserID,
+		limits.RPS,
+		limits.Burst,
+		limits.MaxRecipes,
+		limits.UnlimitedRecipes,
+		limits.MaxImageBytes,
+		limits.UnlimitedImageBytes,
+		limits.Version,
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&limits.Version)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrEditConflict
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Delete removes the user's overrides, returning them to the server-wide defaults.
+func (m LimitModel) Delete(userID int64) error {
+	query := `
+		DELETE FROM user_limits
+		WHERE user_id = $1`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, userID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
+
+// GetUsage counts the recipes the user has created and the bytes of images they have
+// uploaded.
+func (m LimitModel) GetUsage(userID int64) (*StorageUsage, error) {
+	query := `
+		SELECT
+			(SELECT count(*) FROM recipes WHERE user_id = $1),
+			(SELECT COALESCE(sum(octet_length(data)), 0) FROM images WHERE user_id = $1)`
+
+	var usage StorageUsage
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, userID).Scan(&usage.Recipes, &usage.ImageBytes)
+	if err != nil {
+		return nil, err
+	}
+
+	return &usage, nil
+}
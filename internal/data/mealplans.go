@@ -0,0 +1,306 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"eatinn.dcashman.net/internal/validator"
+	"github.com/lib/pq"
+)
+
+// MealSafelist holds the meals that a recipe can be planned for.
+var MealSafelist = []string{"breakfast", "lunch", "dinner", "snack"}
+
+// MealPlanEntry schedules a number of servings of a recipe for a meal on a given day.
+type MealPlanEntry struct {
+	ID         int64     `json:"id"`
+	CreatedAt  time.Time `json:"-"`
+	UserID     int64     `json:"user_id"`
+	RecipeID   int64     `json:"recipe_id"`
+	RecipeName string    `json:"recipe_name,omitempty"`
+	PlannedFor Date      `json:"planned_for"`
+	Meal       string    `json:"meal"`
+	Servings   float64   `json:"servings"`
+	Version    int32     `json:"version"`
+}
+
+func ValidateMealPlanEntry(v *validator.Validator, entry *MealPlanEntry) {
+	v.Check(entry.RecipeID > 0, "recipe_id", "must be provided")
+	v.Check(!time.Time(entry.PlannedFor).IsZero(), "planned_for", "must be provided")
+	v.Check(validator.PermittedValue(entry.Meal, MealSafelist...), "meal", "must be one of breakfast, lunch, dinner or snack")
+	v.Check(entry.Servings > 0, "servings", "must be greater than zero")
+	v.Check(entry.Servings <= 100, "servings", "must not be more than 100")
+}
+
+// ValidateDateRange checks a from/to date range used to query the meal plan.
+func ValidateDateRange(v *validator.Validator, from, to time.Time, maxDays int) {
+	v.Check(!to.Before(from), "to", "must not be before from")
+	v.Check(to.Sub(from) < time.Duration(maxDays)*24*time.Hour, "to", fmt.Sprintf("must be within %d days of from", maxDays))
+}
+
+// Define a MealPlanModel struct type which wraps a sql.DB connection pool.
+type MealPlanModel struct {
+	DB *sql.DB
+}
+
+// Insert adds a new entry to a user's meal plan.
+func (m MealPlanModel) Insert(entry *MealPlanEntry) error {
+	query := `
+		INSERT INTO meal_plan_entries (user_id, recipe_id, planned_for, meal, servings)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, created_at, version`
+
+	args := []any{entry.UserID, entry.RecipeID, time.Time(entry.PlannedFor).Format(DateLayout), entry.Meal, entry.Servings}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	return m.DB.QueryRowContext(ctx, query, args...).Scan(&entry.ID, &entry.CreatedAt, &entry.Version)
+}
+
+// Get fetches a specific meal plan entry by ID.
+func (m MealPlanModel) Get(id int64) (*MealPlanEntry, error) {
+	if id < 1 {
+		return nil, ErrRecordNotFound
+	}
+
+	query := `
+		SELECT e.id, e.created_at, e.user_id, e.recipe_id, r.name, e.planned_for, e.meal, e.servings, e.version
+		FROM meal_plan_entries e
+		INNER JOIN recipes r ON r.id = e.recipe_id
+		WHERE e.id = $1`
+
+	var entry MealPlanEntry
+	var plannedFor time.Time
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, id).Scan(
+		&entry.ID,
+		&entry.CreatedAt,
+		&entry.UserID,
+		&entry.RecipeID,
+		&entry.RecipeName,
+		&plannedFor,
+		&entry.Meal,
+		&entry.Servings,
+		&entry.Version,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	entry.PlannedFor = Date(plannedFor)
+
+	return &entry, nil
+}
+
+// GetAllForUser returns the user's meal plan entries between the from and to dates
+// (inclusive), in date order.
+func (m MealPlanModel) GetAllForUser(userID int64, from, to time.Time) ([]*MealPlanEntry, error) {
+	query := `
+		SELECT e.id, e.created_at, e.user_id, e.recipe_id, r.name, e.planned_for, e.meal, e.servings, e.version
+		FROM meal_plan_entries e
+		INNER JOIN recipes r ON r.id = e.recipe_id
+		WHERE e.user_id = $1 AND e.planned_for BETWEEN $2::date AND $3::date
+		ORDER BY e.planned_for, array_position($4::text[], e.meal), e.id`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, userID, from.Format(DateLayout), to.Format(DateLayout), pq.Array(MealSafelist))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := []*MealPlanEntry{}
+
+	for rows.Next() {
+		var entry MealPlanEntry
+		var plannedFor time.Time
+
+		err := rows.Scan(
+			&entry.ID,
+			&entry.CreatedAt,
+			&entry.UserID,
+			&entry.RecipeID,
+			&entry.RecipeName,
+			&plannedFor,
+			&entry.Meal,
+			&entry.Servings,
+			&entry.Version,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		entry.PlannedFor = Date(plannedFor)
+		entries = append(entries, &entry)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return entries, nil
+}
+
+// Delete removes a meal plan entry.
+func (m MealPlanModel) Delete(id int64) error {
+	if id < 1 {
+		return ErrRecordNotFound
+	}
+
+	query := `DELETE FROM meal_plan_entries WHERE id = $1`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, id)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
+
+// GetDailyNutrition sums the per-serving nutrition of the user's planned meals,
+// multiplied by the planned servings, for each day between from and to (inclusive).
+// Every day in the range is returned, including days with nothing planned. Recipes
+// without nutrition information are counted but contribute nothing to the totals.
+func (m MealPlanModel) GetDailyNutrition(userID int64, from, to time.Time) ([]*DailyNutrition, error) {
+	query := `
+		SELECT d.day::date,
+		       COUNT(e.id),
+		       COUNT(e.id) FILTER (WHERE r.calories IS NULL),
+		       COALESCE(SUM(r.calories * e.servings), 0),
+		       COALESCE(SUM(r.protein_grams * e.servings), 0),
+		       COALESCE(SUM(r.carbohydrate_grams * e.servings), 0),
+		       COALESCE(SUM(r.fat_grams * e.servings), 0)
+		FROM generate_series($2::date, $3::date, interval '1 day') AS d(day)
+		LEFT JOIN meal_plan_entries e ON e.planned_for = d.day::date AND e.user_id = $1
+		LEFT JOIN recipes r ON r.id = e.recipe_id
+		GROUP BY d.day
+		ORDER BY d.day`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, userID, from.Format(DateLayout), to.Format(DateLayout))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	days := []*DailyNutrition{}
+
+	for rows.Next() {
+		var day DailyNutrition
+		var date time.Time
+
+		err := rows.Scan(
+			&date,
+			&day.Meals,
+			&day.RecipesMissingNutrition,
+			&day.Totals.Calories,
+			&day.Totals.ProteinGrams,
+			&day.Totals.CarbohydrateGrams,
+			&day.Totals.FatGrams,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		day.Date = Date(date)
+		days = append(days, &day)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return days, nil
+}
+
+// ShoppingListItem is an ingredient needed for the meals planned over a date range.
+// Amounts are recorded as free text on recipes, so the amounts from each recipe are
+// listed separately rather than added up.
+type ShoppingListItem struct {
+	Ingredient string   `json:"ingredient"`
+	Amounts    []string `json:"amounts"`
+	Optional   bool     `json:"optional"`
+	RecipeIDs  []int64  `json:"recipe_ids"`
+}
+
+// GetShoppingList returns the ingredients of every recipe in the user's meal plan
+// between the from and to dates (inclusive), in alphabetical order. An ingredient is
+// only optional if it is optional in every recipe that uses it.
+func (m MealPlanModel) GetShoppingList(userID int64, from, to time.Time) ([]*ShoppingListItem, error) {
+	query := `
+		SELECT i.name,
+		       array_agg(DISTINCT TRIM(ri.quantity || ' ' || ri.unit)),
+		       bool_and(ri.optional),
+		       array_agg(DISTINCT e.recipe_id)
+		FROM meal_plan_entries e
+		INNER JOIN recipe_ingredients ri ON ri.recipe_id = e.recipe_id
+		INNER JOIN ingredients i ON i.id = ri.ingredient_id
+		WHERE e.user_id = $1 AND e.planned_for BETWEEN $2::date AND $3::date
+		GROUP BY i.name
+		ORDER BY i.name`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, userID, from.Format(DateLayout), to.Format(DateLayout))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := []*ShoppingListItem{}
+
+	for rows.Next() {
+		var item ShoppingListItem
+
+		err := rows.Scan(
+			&item.Ingredient,
+			pq.Array(&item.Amounts),
+			&item.Optional,
+			pq.Array(&item.RecipeIDs),
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		// Ingredients without an amount, such as "salt", have nothing to list.
+		item.Amounts = slices.DeleteFunc(item.Amounts, func(a string) bool { return a == "" })
+
+		items = append(items, &item)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return items, nil
+}
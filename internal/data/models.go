@@ -44,20 +44,72 @@ func (d *Duration) UnmarshalJSON(b []byte) error {
 	}
 }
 
+// Date wraps time.Time to provide custom JSON marshaling/unmarshaling for calendar
+// dates. It accepts and outputs dates in the "2006-01-02" format.
+type Date time.Time
+
+// DateLayout is the format used for dates in JSON and query strings.
+const DateLayout = "2006-01-02"
+
+// MarshalJSON implements the json.Marshaler interface.
+func (d Date) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Time(d).Format(DateLayout))
+}
+
+// UnmarshalJSON implements the json.Unmarshaler interface.
+func (d *Date) UnmarshalJSON(b []byte) error {
+	var value string
+	if err := json.Unmarshal(b, &value); err != nil {
+		return fmt.Errorf("date must be a string (e.g., \"2006-01-02\")")
+	}
+
+	t, err := time.Parse(DateLayout, value)
+	if err != nil {
+		return fmt.Errorf("invalid date format: %w", err)
+	}
+
+	*d = Date(t)
+	return nil
+}
+
 // Create a Models struct which wraps the RecipeModel. We'll add other models to this,
 // like a UserModel and PermissionModel, as our build progresses.
 type Models struct {
-	Recipes RecipeModel
-	Users   UserModel
-	Tokens  TokenModel
+	Recipes        RecipeModel
+	Users          UserModel
+	Tokens         TokenModel
+	Permissions    PermissionModel
+	Reports        ReportModel
+	Idempotency    IdempotencyModel
+	Ingredients    IngredientModel
+	MealPlans      MealPlanModel
+	NutritionGoals NutritionGoalModel
+	Gallery        GalleryModel
+	Household      HouseholdMemberModel
+	Images         ImageModel
+	Collaborators  CollaboratorModel
+	Limits         LimitModel
+	Favorites      FavoriteModel
 }
 
 // For ease of use, we also add a New() method which returns a Models struct containing
 // the initialized RecipeModel.
 func NewModels(db *sql.DB) Models {
 	return Models{
-		Recipes: RecipeModel{DB: db},
-		Users:   UserModel{DB: db},
-		Tokens:  TokenModel{DB: db},
+		Recipes:        RecipeModel{DB: db},
+		Users:          UserModel{DB: db},
+		Tokens:         TokenModel{DB: db},
+		Permissions:    PermissionModel{DB: db},
+		Reports:        ReportModel{DB: db},
+		Idempotency:    IdempotencyModel{DB: db},
+		Ingredients:    IngredientModel{DB: db},
+		MealPlans:      MealPlanModel{DB: db},
+		NutritionGoals: NutritionGoalModel{DB: db},
+		Gallery:        GalleryModel{DB: db},
+		Household:      HouseholdMemberModel{DB: db},
+		Images:         ImageModel{DB: db},
+		Collaborators:  CollaboratorModel{DB: db},
+		Limits:         LimitModel{DB: db},
+		Favorites:      FavoriteModel{DB: db},
 	}
 }
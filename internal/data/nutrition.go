@@ -0,0 +1,160 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"eatinn.dcashman.net/internal/validator"
+)
+
+// NutritionGoalTolerance is how far (as a fraction of the target) a day's total may
+// stray from a goal while still being considered on target.
+const NutritionGoalTolerance = 0.1
+
+// Nutrient statuses reported when comparing a day's totals against the user's goals.
+const (
+	NutrientUnder  = "under"
+	NutrientWithin = "within"
+	NutrientOver   = "over"
+)
+
+// Nutrition holds calorie and macronutrient amounts. On a recipe these are per serving.
+type Nutrition struct {
+	Calories          float64 `json:"calories"`
+	ProteinGrams      float64 `json:"protein_grams"`
+	CarbohydrateGrams float64 `json:"carbohydrate_grams"`
+	FatGrams          float64 `json:"fat_grams"`
+}
+
+// NutritionGoals holds a user's daily targets. A zero target means that the user hasn't
+// set a goal for that nutrient.
+type NutritionGoals struct {
+	UserID int64 `json:"-"`
+	Nutrition
+	Version int32 `json:"version"`
+}
+
+// DailyNutrition summarises the planned meals for a single day against the user's
+// goals. Status holds an entry for each nutrient which has a goal set.
+type DailyNutrition struct {
+	Date                    Date              `json:"date"`
+	Meals                   int               `json:"meals"`
+	RecipesMissingNutrition int               `json:"recipes_missing_nutrition"`
+	Totals                  Nutrition         `json:"totals"`
+	Status                  map[string]string `json:"status"`
+	ExceedsGoals            bool              `json:"exceeds_goals"`
+	BelowGoals              bool              `json:"below_goals"`
+}
+
+func ValidateNutrition(v *validator.Validator, n *Nutrition) {
+	v.Check(n.Calories >= 0, "calories", "must not be negative")
+	v.Check(n.ProteinGrams >= 0, "protein_grams", "must not be negative")
+	v.Check(n.CarbohydrateGrams >= 0, "carbohydrate_grams", "must not be negative")
+	v.Check(n.FatGrams >= 0, "fat_grams", "must not be negative")
+}
+
+// CompareToGoals fills in the Status, ExceedsGoals and BelowGoals fields of the day by
+// comparing its totals against the given goals.
+func (d *DailyNutrition) CompareToGoals(goals *NutritionGoals) {
+	nutrients := []struct {
+		name   string
+		total  float64
+		target float64
+	}{
+		{"calories", d.Totals.Calories, goals.Calories},
+		{"protein_grams", d.Totals.ProteinGrams, goals.ProteinGrams},
+		{"carbohydrate_grams", d.Totals.CarbohydrateGrams, goals.CarbohydrateGrams},
+		{"fat_grams", d.Totals.FatGrams, goals.FatGrams},
+	}
+
+	d.Status = make(map[string]string)
+
+	for _, n := range nutrients {
+		if n.target <= 0 {
+			continue
+		}
+
+		switch {
+		case n.total > n.target*(1+NutritionGoalTolerance):
+			d.Status[n.name] = NutrientOver
+			d.ExceedsGoals = true
+		case n.total < n.target*(1-NutritionGoalTolerance):
+			d.Status[n.name] = NutrientUnder
+			d.BelowGoals = true
+		default:
+			d.Status[n.name] = NutrientWithin
+		}
+	}
+}
+
+// Define a NutritionGoalModel struct type which wraps a sql.DB connection pool.
+type NutritionGoalModel struct {
+	DB *sql.DB
+}
+
+// GetForUser fetches the user's goals. Users who have never set any goals get a zero
+// value (with version 0), rather than an error.
+func (m NutritionGoalModel) GetForUser(userID int64) (*NutritionGoals, error) {
+	query := `
+		SELECT calories, protein_grams, carbohydrate_grams, fat_grams, version
+		FROM nutrition_goals
+		WHERE user_id = $1`
+
+	goals := NutritionGoals{UserID: userID}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, userID).Scan(
+		&goals.Calories,
+		&goals.ProteinGrams,
+		&goals.CarbohydrateGrams,
+		&goals.FatGrams,
+		&goals.Version,
+	)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+
+	return &goals, nil
+}
+
+// Upsert creates or updates the user's goals, using the version field for optimistic
+// locking. A version of 0 means that the goals are being created for the first time.
+func (m NutritionGoalModel) Upsert(goals *NutritionGoals) error {
+	query := `
+		INSERT INTO nutrition_goals (user_id, calories, protein_grams, carbohydrate_grams, fat_grams)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (user_id) DO UPDATE
+		SET calories = EXCLUDED.calories, protein_grams = EXCLUDED.protein_grams,
+		    carbohydrate_grams = EXCLUDED.carbohydrate_grams, fat_grams = EXCLUDED.fat_grams,
+		    version = nutrition_goals.version + 1
+		WHERE nutrition_goals.version = $6
+		RETURNING version`
+
+	args := []any{
+		goals.UserID,
+		goals.Calories,
+		goals.ProteinGrams,
+		goals.CarbohydrateGrams,
+		goals.FatGrams,
+		goals.Version,
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&goals.Version)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrEditConflict
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,212 @@
+package data
+
+import (
+	"fmt"
+	"time"
+
+	"eatinn.dcashman.net/internal/validator"
+)
+
+// The .eatinn recipe file format is a self-contained JSON document holding a single
+// recipe, so that recipes can be shared between separate eatinn servers. The version is
+// incremented whenever the format changes in a way that older servers can't read.
+const (
+	RecipeFileFormat    = "eatinn.recipe"
+	RecipeFileVersion   = 1
+	RecipeFileExtension = ".eatinn"
+)
+
+// RecipeFile is the top level of a .eatinn file.
+type RecipeFile struct {
+	Format     string           `json:"format"`
+	Version    int              `json:"version"`
+	ExportedAt time.Time        `json:"exported_at"`
+	Recipe     RecipeFileRecipe `json:"recipe"`
+}
+
+// RecipeFileImage is an image in a .eatinn file. The image data is normally embedded
+// (and base64 encoded in the JSON), but may instead be a URL reference if the image
+// couldn't be embedded when the file was exported.
+type RecipeFileImage struct {
+	URL         string `json:"url,omitempty"`
+	ContentType string `json:"content_type,omitempty"`
+	Data        []byte `json:"data,omitempty"`
+}
+
+// RecipeFileRecipe holds the portable parts of a recipe. Server-specific details such as
+// IDs, owner and visibility are deliberately left out.
+type RecipeFileRecipe struct {
+	Name              string                   `json:"name"`
+	Description       string                   `json:"description,omitempty"`
+	Ingredients       []RecipeFileIngredient   `json:"ingredients,omitempty"`
+	RequiredEquipment []string                 `json:"required_equipment,omitempty"`
+	Instructions      []RecipeFileStep         `json:"instructions,omitempty"`
+	Notes             string                   `json:"notes,omitempty"`
+	SourceURL         string                   `json:"source_url,omitempty"`
+	PrepTime          Duration                 `json:"prep_time,omitempty"`
+	ActiveTime        Duration                 `json:"active_time,omitempty"`
+	Servings          int32                    `json:"servings,omitempty"`
+	Nutrition         *Nutrition               `json:"nutrition,omitempty"`
+	DisplayImage      *RecipeFileImage         `json:"display_image,omitempty"`
+	Gallery           []RecipeFileGalleryImage `json:"gallery,omitempty"`
+}
+
+type RecipeFileIngredient struct {
+	Ingredient string `json:"ingredient"`
+	Amount     string `json:"amount"`
+	Unit       string `json:"unit"`
+	Optional   bool   `json:"optional"`
+}
+
+type RecipeFileStep struct {
+	StepNumber int64             `json:"step_number"`
+	Text       string            `json:"text"`
+	Notes      string            `json:"notes,omitempty"`
+	Images     []RecipeFileImage `json:"images,omitempty"`
+}
+
+type RecipeFileGalleryImage struct {
+	RecipeFileImage
+	Caption string `json:"caption,omitempty"`
+}
+
+// NewRecipeFile converts a recipe to the .eatinn format. Images are included as URL
+// references; the caller is responsible for embedding their data.
+func NewRecipeFile(recipe *Recipe) *RecipeFile {
+	f := &RecipeFile{
+		Format:     RecipeFileFormat,
+		Version:    RecipeFileVersion,
+		ExportedAt: time.Now().UTC().Truncate(time.Second),
+		Recipe: RecipeFileRecipe{
+			Name:              recipe.Name,
+			Description:       recipe.Description,
+			RequiredEquipment: recipe.RequiredEquipment,
+			Notes:             recipe.Notes,
+			SourceURL:         recipe.SourceURL,
+			PrepTime:          recipe.PrepTime,
+			ActiveTime:        recipe.ActiveTime,
+			Servings:          recipe.Servings,
+			Nutrition:         recipe.Nutrition,
+		},
+	}
+
+	for _, entry := range recipe.Ingredients {
+		f.Recipe.Ingredients = append(f.Recipe.Ingredients, RecipeFileIngredient{
+			Ingredient: entry.Ingredient,
+			Amount:     entry.Amount,
+			Unit:       entry.Unit,
+			Optional:   entry.Optional,
+		})
+	}
+
+	for _, step := range recipe.Instructions {
+		fileStep := RecipeFileStep{StepNumber: step.StepNumber, Text: step.Text, Notes: step.Notes}
+		for _, url := range step.ImageURLs {
+			fileStep.Images = append(fileStep.Images, RecipeFileImage{URL: url})
+		}
+		f.Recipe.Instructions = append(f.Recipe.Instructions, fileStep)
+	}
+
+	if recipe.DisplayURL != "" {
+		f.Recipe.DisplayImage = &RecipeFileImage{URL: recipe.DisplayURL}
+	}
+
+	for _, image := range recipe.Gallery {
+		f.Recipe.Gallery = append(f.Recipe.Gallery, RecipeFileGalleryImage{
+			RecipeFileImage: RecipeFileImage{URL: image.URL},
+			Caption:         image.Caption,
+		})
+	}
+
+	return f
+}
+
+// Images returns pointers to every image in the file, so that they can be embedded on
+// export or stored on import.
+func (f *RecipeFile) Images() []*RecipeFileImage {
+	var images []*RecipeFileImage
+
+	if f.Recipe.DisplayImage != nil {
+		images = append(images, f.Recipe.DisplayImage)
+	}
+	for i := range f.Recipe.Instructions {
+		for j := range f.Recipe.Instructions[i].Images {
+			images = append(images, &f.Recipe.Instructions[i].Images[j])
+		}
+	}
+	for i := range f.Recipe.Gallery {
+		images = append(images, &f.Recipe.Gallery[i].RecipeFileImage)
+	}
+
+	return images
+}
+
+// ToRecipe converts the file to a new, private recipe owned by userID. Every image must
+// have a URL by this point, so embedded images need to be stored first.
+func (f *RecipeFile) ToRecipe(userID int64) *Recipe {
+	recipe := &Recipe{
+		Name:              f.Recipe.Name,
+		Description:       f.Recipe.Description,
+		Ingredients:       []IngredientEntry{},
+		RequiredEquipment: f.Recipe.RequiredEquipment,
+		Instructions:      []InstructionStep{},
+		Notes:             f.Recipe.Notes,
+		SourceURL:         f.Recipe.SourceURL,
+		PrepTime:          f.Recipe.PrepTime,
+		ActiveTime:        f.Recipe.ActiveTime,
+		Servings:          f.Recipe.Servings,
+		Nutrition:         f.Recipe.Nutrition,
+		UserID:            userID,
+	}
+	if recipe.RequiredEquipment == nil {
+		recipe.RequiredEquipment = []string{}
+	}
+
+	for _, entry := range f.Recipe.Ingredients {
+		recipe.Ingredients = append(recipe.Ingredients, IngredientEntry{
+			Ingredient: entry.Ingredient,
+			Amount:     entry.Amount,
+			Unit:       entry.Unit,
+			Optional:   entry.Optional,
+		})
+	}
+
+	for _, fileStep := range f.Recipe.Instructions {
+		step := InstructionStep{StepNumber: fileStep.StepNumber, Text: fileStep.Text, Notes: fileStep.Notes}
+		for _, image := range fileStep.Images {
+			step.ImageURLs = append(step.ImageURLs, image.URL)
+		}
+		recipe.Instructions = append(recipe.Instructions, step)
+	}
+
+	if f.Recipe.DisplayImage != nil {
+		recipe.DisplayURL = f.Recipe.DisplayImage.URL
+	}
+
+	for _, image := range f.Recipe.Gallery {
+		recipe.Gallery = append(recipe.Gallery, GalleryImage{URL: image.URL, Caption: image.Caption})
+	}
+
+	return recipe
+}
+
+func ValidateRecipeFile(v *validator.Validator, f *RecipeFile) {
+	v.Check(f.Format == RecipeFileFormat, "format", fmt.Sprintf("must be %q", RecipeFileFormat))
+	v.Check(f.Version >= 1, "version", "must be provided")
+	v.Check(f.Version <= RecipeFileVersion, "version", fmt.Sprintf("is not supported (the newest supported version is %d)", RecipeFileVersion))
+	v.Check(len(f.Recipe.Gallery) <= MaxGalleryImages, "recipe.gallery", fmt.Sprintf("must not contain more than %d images", MaxGalleryImages))
+
+	for _, image := range f.Images() {
+		if len(image.Data) > 0 {
+			v.Check(image.URL == "", "recipe.images", "must not have both a url and embedded data")
+			v.Check(len(image.Data) <= MaxImageBytes, "recipe.images", fmt.Sprintf("must not be more than %d bytes each", MaxImageBytes))
+		} else {
+			v.Check(image.URL != "", "recipe.images", "must have either a url or embedded data")
+			v.Check(len(image.URL) <= 2048, "recipe.images", "must not have urls more than 2048 bytes long")
+		}
+	}
+
+	for _, image := range f.Recipe.Gallery {
+		v.Check(len(image.Caption) <= 500, "recipe.gallery", "must not have captions more than 500 bytes long")
+	}
+}
@@ -6,9 +6,11 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"strings"
 	"time"
 
 	"eatinn.dcashman.net/internal/validator"
+	"github.com/lib/pq"
 )
 
 type IngredientEntry struct {
@@ -28,22 +30,30 @@ type InstructionStep struct {
 }
 
 type Recipe struct {
-	ID                int64             `json:"id"`                           // Unique integer ID for the recipe
-	CreatedAt         time.Time         `json:"-"`                            // Timestamp for when the recipe is added to our database
-	Name              string            `json:"name"`                         // Name of the dish which the recipe creates
-	Description       string            `json:"description,omitempty"`        // Description of the dish which the recipe creates
-	Ingredients       []IngredientEntry `json:"ingredients,omitempty"`        // List of ingredients needed to make recipe
-	RequiredEquipment []string          `json:"required_equipment,omitempty"` // Any notable equipment required to make the recipe
-	Instructions      []InstructionStep `json:"instructions,omitempty"`       // Steps to make the dish.
-	Notes             string            `json:"notes,omitempty"`              // Additional notes added to the recipe, not attached to any step.
-	DisplayURL        string            `json:"display_url,omitempty"`        // URL of the image to display for this recipe
-	SourceURL         string            `json:"source_url,omitempty"`         // Source of the recipe
-	PrepTime          Duration          `json:"prep_time,omitempty"`          // The wall-clock time required to make the recipe.
-	ActiveTime        Duration          `json:"active_time,omitempty"`        // The amount of time actively preparing the recipe, rather than passively waiting.
-	UserID            int64             `json:"user_id"`                      // ID of the user who created this recipe
-	Public            bool              `json:"public"`                       // Whether or not this recipe should be made globally available.
-	Servings          int32             `json:"servings,omitempty"`           // Number of servings for this recipe
-	Version           int32             `json:"version"`                      // The version number starts at 1 and will be incremented each time the recipe is updated
+	ID                     int64             `json:"id"`                                 // Unique integer ID for the recipe
+	CreatedAt              time.Time         `json:"-"`                                  // Timestamp for when the recipe is added to our database
+	Name                   string            `json:"name"`                               // Name of the dish which the recipe creates
+	Description            string            `json:"description,omitempty"`              // Description of the dish which the recipe creates
+	Ingredients            []IngredientEntry `json:"ingredients,omitempty"`              // List of ingredients needed to make recipe
+	RequiredEquipment      []string          `json:"required_equipment,omitempty"`       // Any notable equipment required to make the recipe
+	Instructions           []InstructionStep `json:"instructions,omitempty"`             // Steps to make the dish.
+	Notes                  string            `json:"notes,omitempty"`                    // Additional notes added to the recipe, not attached to any step.
+	DisplayURL             string            `json:"display_url,omitempty"`              // URL of the image to display for this recipe
+	Gallery                []GalleryImage    `json:"gallery,omitempty"`                  // Additional user-ordered photos of the dish
+	SourceURL              string            `json:"source_url,omitempty"`               // Source of the recipe
+	PrepTime               Duration          `json:"prep_time,omitempty"`                // The wall-clock time required to make the recipe.
+	ActiveTime             Duration          `json:"active_time,omitempty"`              // The amount of time actively preparing the recipe, rather than passively waiting.
+	UserID                 int64             `json:"user_id"`                            // ID of the user who created this recipe
+	Public                 bool              `json:"public"`                             // Whether or not this recipe should be made globally available.
+	PublishAt              *time.Time        `json:"publish_at,omitempty"`               // When a private draft is scheduled to become public
+	UnpublishedByModerator bool              `json:"unpublished_by_moderator,omitempty"` // Whether a moderator has made the recipe private, stopping it being made public again
+	Servings               int32             `json:"servings,omitempty"`                 // Number of servings for this recipe
+	Nutrition              *Nutrition        `json:"nutrition,omitempty"`                // Calories and macronutrients per serving, if known
+	Version                int32             `json:"version"`                            // The version number starts at 1 and will be incremented each time the recipe is updated
+
+	// collaborators maps the IDs of users who have been granted access to the recipe to
+	// their access level. It is only populated by Get(), GetMany() and GetStepRecipe().
+	collaborators map[int64]string
 }
 
 func ValidateRecipe(v *validator.Validator, r *Recipe) {
@@ -54,6 +64,39 @@ func ValidateRecipe(v *validator.Validator, r *Recipe) {
 	// is less than or equal to 500 bytes" and so on.
 	v.Check(r.Name != "", "name", "must be provided")
 	v.Check(len(r.Name) <= 500, "name", "must not be more than 500 bytes long")
+
+	if r.Nutrition != nil {
+		ValidateNutrition(v, r.Nutrition)
+	}
+
+	v.Check(r.PublishAt == nil || !r.Public, "publish_at", "can only be set on private recipes")
+}
+
+// VisibleTo reports whether the given user is allowed to read the recipe. Public
+// recipes are visible to everyone, private recipes only to their creator and
+// collaborators.
+func (r *Recipe) VisibleTo(user *User) bool {
+	return r.Public || r.EditableBy(user) || r.CollaboratorAccess(user) == CollaboratorRead
+}
+
+// EditableBy reports whether the given user is allowed to change the recipe, either
+// because they created it or because they have been granted edit access.
+func (r *Recipe) EditableBy(user *User) bool {
+	if user.IsAnonymous() {
+		return false
+	}
+
+	return r.UserID == user.ID || r.CollaboratorAccess(user) == CollaboratorEdit
+}
+
+// CollaboratorAccess returns the access level the user has been granted as a
+// collaborator on the recipe, or an empty string if they aren't a collaborator.
+func (r *Recipe) CollaboratorAccess(user *User) string {
+	if user.IsAnonymous() {
+		return ""
+	}
+
+	return r.collaborators[user.ID]
 }
 
 // Define a RecipeModel struct type which wraps a sql.DB connection pool.
@@ -81,7 +124,23 @@ func durationToInterval(d time.Duration) *string {
 	return &interval
 }
 
+// nutritionArgs returns the values for the calories, protein_grams, carbohydrate_grams
+// and fat_grams columns, all of which are NULL when the nutrition is unknown.
+func nutritionArgs(n *Nutrition) []any {
+	if n == nil {
+		return []any{nil, nil, nil, nil}
+	}
+	return []any{n.Calories, n.ProteinGrams, n.CarbohydrateGrams, n.FatGrams}
+}
+
 func (r RecipeModel) Insert(recipe *Recipe) error {
+	return r.InsertWithImages(recipe, nil)
+}
+
+// InsertWithImages inserts a recipe together with the stored images it refers to, in a
+// single transaction so that a failure doesn't leave orphaned images behind. The images
+// must already have keys (see NewImageKey), so that the recipe can use their URLs.
+func (r RecipeModel) InsertWithImages(recipe *Recipe, images []*StoredImage) error {
 
 	tx, err := r.DB.Begin()
 	if err != nil {
@@ -89,6 +148,17 @@ func (r RecipeModel) Insert(recipe *Recipe) error {
 	}
 	defer tx.Rollback()
 
+	for _, image := range images {
+		err := tx.QueryRow(`
+			INSERT INTO images (key, user_id, content_type, data)
+			VALUES ($1, $2, $3, $4)
+			RETURNING created_at
+		`, image.Key, image.UserID, image.ContentType, image.Data).Scan(&image.CreatedAt)
+		if err != nil {
+			return err
+		}
+	}
+
 	instructionsJSON, err := json.Marshal(recipe.Instructions)
 	if err != nil {
 		return err
@@ -96,12 +166,15 @@ func (r RecipeModel) Insert(recipe *Recipe) error {
 
 	query := `
 		INSERT INTO recipes
-		(name, description, instructions, notes, source_url, prep_time, active_time, servings, user_id)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		(name, description, instructions, notes, source_url, prep_time, active_time, servings, user_id, public,
+		 calories, protein_grams, carbohydrate_grams, fat_grams, publish_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15)
 		RETURNING id, created_at, version`
 
 	// Convert data.Duration to PostgreSQL interval strings for database storage
-	args := []any{recipe.Name, recipe.Description, instructionsJSON, recipe.Notes, recipe.SourceURL, durationToInterval(time.Duration(recipe.PrepTime)), durationToInterval(time.Duration(recipe.ActiveTime)), nilIfZero(recipe.Servings), recipe.UserID}
+	args := []any{recipe.Name, recipe.Description, instructionsJSON, recipe.Notes, recipe.SourceURL, durationToInterval(time.Duration(recipe.PrepTime)), durationToInterval(time.Duration(recipe.ActiveTime)), nilIfZero(recipe.Servings), recipe.UserID, recipe.Public}
+	args = append(args, nutritionArgs(recipe.Nutrition)...)
+	args = append(args, recipe.PublishAt)
 	err = tx.QueryRow(
 		query,
 		args...,
@@ -194,6 +267,19 @@ func (r RecipeModel) Insert(recipe *Recipe) error {
 		}
 	}
 
+	for i := range recipe.Gallery {
+		image := &recipe.Gallery[i]
+		image.Position = int32(i + 1)
+		err := tx.QueryRow(`
+			INSERT INTO recipe_images (recipe_id, image_url, image_type, caption, position)
+			VALUES ($1, $2, 'gallery', $3, $4)
+			RETURNING id, uploaded_at
+		`, recipe.ID, image.URL, nilIfZero(image.Caption), image.Position).Scan(&image.ID, &image.UploadedAt)
+		if err != nil {
+			return err
+		}
+	}
+
 	return tx.Commit()
 }
 
@@ -210,7 +296,8 @@ func (r RecipeModel) Get(id int64) (*Recipe, error) {
 		SELECT id, created_at, name, description, notes, source_url,
 		       EXTRACT(EPOCH FROM prep_time) as prep_time,
 		       EXTRACT(EPOCH FROM active_time) as active_time,
-		       servings, user_id, version
+		       servings, user_id, public, publish_at, unpublished_by_moderator, version,
+		       calories, protein_grams, carbohydrate_grams, fat_grams
 		FROM recipes
 		WHERE id = $1`
 
@@ -218,6 +305,8 @@ func (r RecipeModel) Get(id int64) (*Recipe, error) {
 	var description, notes, sourceURL sql.NullString
 	var prepTimeSeconds, activeTimeSeconds sql.NullFloat64
 	var servings sql.NullInt32
+	var calories, protein, carbohydrate, fat sql.NullFloat64
+	var publishAt sql.NullTime
 
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
@@ -233,7 +322,14 @@ func (r RecipeModel) Get(id int64) (*Recipe, error) {
 		&activeTimeSeconds,
 		&servings,
 		&recipe.UserID,
+		&recipe.Public,
+		&publishAt,
+		&recipe.UnpublishedByModerator,
 		&recipe.Version,
+		&calories,
+		&protein,
+		&carbohydrate,
+		&fat,
 	)
 
 	if err != nil {
@@ -266,6 +362,17 @@ func (r RecipeModel) Get(id int64) (*Recipe, error) {
 	if servings.Valid {
 		recipe.Servings = servings.Int32
 	}
+	if publishAt.Valid {
+		recipe.PublishAt = &publishAt.Time
+	}
+	if calories.Valid {
+		recipe.Nutrition = &Nutrition{
+			Calories:          calories.Float64,
+			ProteinGrams:      protein.Float64,
+			CarbohydrateGrams: carbohydrate.Float64,
+			FatGrams:          fat.Float64,
+		}
+	}
 
 	// Fetch ingredients
 	ingredientsQuery := `
@@ -411,9 +518,311 @@ func (r RecipeModel) Get(id int64) (*Recipe, error) {
 		recipe.DisplayURL = displayURL.String
 	}
 
+	// Fetch gallery images
+	recipe.Gallery, err = getGallery(ctx, r.DB, id)
+	if err != nil {
+		return nil, err
+	}
+
+	// Fetch collaborators, for the access checks
+	access, err := getCollaboratorAccess(ctx, r.DB, []int64{id})
+	if err != nil {
+		return nil, err
+	}
+	recipe.collaborators = access[id]
+
 	return &recipe, nil
 }
 
+// MaxBatchRecipes is the maximum number of recipes which can be fetched by GetMany().
+const MaxBatchRecipes = 100
+
+// GetMany fetches the full recipes with the given IDs, in the same order as ids, using a
+// fixed number of bulk queries regardless of how many recipes are requested. As with
+// GetAll(), only recipes which viewerID can read are included; IDs which don't exist or
+// aren't visible are skipped.
+func (r RecipeModel) GetMany(ids []int64, viewerID int64) ([]*Recipe, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	query := `
+		SELECT id, created_at, name, description, notes, source_url,
+		       EXTRACT(EPOCH FROM prep_time) as prep_time,
+		       EXTRACT(EPOCH FROM active_time) as active_time,
+		       servings, user_id, public, publish_at, unpublished_by_moderator, version,
+		       calories, protein_grams, carbohydrate_grams, fat_grams
+		FROM recipes
+		WHERE id = ANY($1) AND (public = TRUE OR user_id = $2 OR EXISTS (
+			SELECT 1 FROM recipe_collaborators rc
+			WHERE rc.recipe_id = recipes.id AND rc.user_id = $2))`
+
+	rows, err := r.DB.QueryContext(ctx, query, pq.Array(ids), viewerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	byID := make(map[int64]*Recipe, len(ids))
+	found := []int64{}
+
+	for rows.Next() {
+		var recipe Recipe
+		var description, notes, sourceURL sql.NullString
+		var prepTimeSeconds, activeTimeSeconds sql.NullFloat64
+		var servings sql.NullInt32
+		var calories, protein, carbohydrate, fat sql.NullFloat64
+		var publishAt sql.NullTime
+
+		err := rows.Scan(
+			&recipe.ID,
+			&recipe.CreatedAt,
+			&recipe.Name,
+			&description,
+			&notes,
+			&sourceURL,
+			&prepTimeSeconds,
+			&activeTimeSeconds,
+			&servings,
+			&recipe.UserID,
+			&recipe.Public,
+			&publishAt,
+			&recipe.UnpublishedByModerator,
+			&recipe.Version,
+			&calories,
+			&protein,
+			&carbohydrate,
+			&fat,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		recipe.Description = description.String
+		recipe.Notes = notes.String
+		recipe.SourceURL = sourceURL.String
+		if prepTimeSeconds.Valid {
+			recipe.PrepTime = Duration(time.Duration(prepTimeSeconds.Float64 * float64(time.Second)))
+		}
+		if activeTimeSeconds.Valid {
+			recipe.ActiveTime = Duration(time.Duration(activeTimeSeconds.Float64 * float64(time.Second)))
+		}
+		recipe.Servings = servings.Int32
+		if publishAt.Valid {
+			recipe.PublishAt = &publishAt.Time
+		}
+		if calories.Valid {
+			recipe.Nutrition = &Nutrition{
+				Calories:          calories.Float64,
+				ProteinGrams:      protein.Float64,
+				CarbohydrateGrams: carbohydrate.Float64,
+				FatGrams:          fat.Float64,
+			}
+		}
+
+		recipe.Ingredients = []IngredientEntry{}
+		recipe.RequiredEquipment = []string{}
+		recipe.Instructions = []InstructionStep{}
+		recipe.Gallery = []GalleryImage{}
+
+		byID[recipe.ID] = &recipe
+		found = append(found, recipe.ID)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(found) > 0 {
+		err = r.loadRecipeDetails(ctx, found, byID)
+		if err != nil {
+			return nil, err
+		}
+
+		access, err := getCollaboratorAccess(ctx, r.DB, found)
+		if err != nil {
+			return nil, err
+		}
+		for id, recipe := range byID {
+			recipe.collaborators = access[id]
+		}
+	}
+
+	recipes := []*Recipe{}
+	for _, id := range ids {
+		if recipe, ok := byID[id]; ok {
+			recipes = append(recipes, recipe)
+		}
+	}
+
+	return recipes, nil
+}
+
+// loadRecipeDetails fills in the ingredients, equipment, instructions and images of the
+// given recipes, with one query for each kind of related data.
+func (r RecipeModel) loadRecipeDetails(ctx context.Context, ids []int64, byID map[int64]*Recipe) error {
+	// Fetch ingredients
+	rows, err := r.DB.QueryContext(ctx, `
+		SELECT ri.recipe_id, i.id, i.name, ri.quantity, ri.unit, ri.optional
+		FROM ingredients i
+		INNER JOIN recipe_ingredients ri ON i.id = ri.ingredient_id
+		WHERE ri.recipe_id = ANY($1)
+		ORDER BY ri.recipe_id, i.name`, pq.Array(ids))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var recipeID int64
+		var ingredient IngredientEntry
+		err := rows.Scan(
+			&recipeID,
+			&ingredient.ID,
+			&ingredient.Ingredient,
+			&ingredient.Amount,
+			&ingredient.Unit,
+			&ingredient.Optional,
+		)
+		if err != nil {
+			return err
+		}
+		byID[recipeID].Ingredients = append(byID[recipeID].Ingredients, ingredient)
+	}
+
+	if err = rows.Err(); err != nil {
+		return err
+	}
+
+	// Fetch equipment
+	rows, err = r.DB.QueryContext(ctx, `
+		SELECT re.recipe_id, e.name
+		FROM equipment e
+		INNER JOIN recipe_equipment re ON e.id = re.equipment_id
+		WHERE re.recipe_id = ANY($1)
+		ORDER BY re.recipe_id, e.name`, pq.Array(ids))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var recipeID int64
+		var equipmentName string
+		err := rows.Scan(&recipeID, &equipmentName)
+		if err != nil {
+			return err
+		}
+		byID[recipeID].RequiredEquipment = append(byID[recipeID].RequiredEquipment, equipmentName)
+	}
+
+	if err = rows.Err(); err != nil {
+		return err
+	}
+
+	// Fetch instructions
+	rows, err = r.DB.QueryContext(ctx, `
+		SELECT recipe_id, id, step_number, instruction, notes
+		FROM recipe_instructions
+		WHERE recipe_id = ANY($1)
+		ORDER BY recipe_id, step_number`, pq.Array(ids))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var recipeID int64
+		var step InstructionStep
+		var notes sql.NullString
+		err := rows.Scan(&recipeID, &step.ID, &step.StepNumber, &step.Text, &notes)
+		if err != nil {
+			return err
+		}
+		step.Notes = notes.String
+		step.ImageURLs = []string{}
+		byID[recipeID].Instructions = append(byID[recipeID].Instructions, step)
+	}
+
+	if err = rows.Err(); err != nil {
+		return err
+	}
+
+	// Fetch the images for every instruction step
+	rows, err = r.DB.QueryContext(ctx, `
+		SELECT ins.recipe_id, rii.instruction_id, ri.image_url
+		FROM recipe_images ri
+		INNER JOIN recipe_instruction_images rii ON ri.id = rii.image_id
+		INNER JOIN recipe_instructions ins ON ins.id = rii.instruction_id
+		WHERE ins.recipe_id = ANY($1)
+		ORDER BY ri.id`, pq.Array(ids))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var recipeID, instructionID int64
+		var imageURL string
+		err := rows.Scan(&recipeID, &instructionID, &imageURL)
+		if err != nil {
+			return err
+		}
+		steps := byID[recipeID].Instructions
+		for i := range steps {
+			if steps[i].ID == instructionID {
+				steps[i].ImageURLs = append(steps[i].ImageURLs, imageURL)
+				break
+			}
+		}
+	}
+
+	if err = rows.Err(); err != nil {
+		return err
+	}
+
+	// Fetch display (main) and gallery images
+	rows, err = r.DB.QueryContext(ctx, `
+		SELECT id, recipe_id, image_url, image_type, caption, position, uploaded_at
+		FROM recipe_images
+		WHERE recipe_id = ANY($1) AND image_type IN ('main', 'gallery')
+		ORDER BY recipe_id, position, id`, pq.Array(ids))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var image GalleryImage
+		var imageType string
+		var caption sql.NullString
+		var position sql.NullInt32
+		err := rows.Scan(
+			&image.ID,
+			&image.RecipeID,
+			&image.URL,
+			&imageType,
+			&caption,
+			&position,
+			&image.UploadedAt,
+		)
+		if err != nil {
+			return err
+		}
+
+		recipe := byID[image.RecipeID]
+		switch {
+		case imageType == "main" && recipe.DisplayURL == "":
+			recipe.DisplayURL = image.URL
+		case imageType == "gallery":
+			image.Caption = caption.String
+			image.Position = position.Int32
+			recipe.Gallery = append(recipe.Gallery, image)
+		}
+	}
+
+	return rows.Err()
+}
+
 // Update modifies an existing recipe in the database. It uses optimistic locking
 // via the version field to prevent race conditions.
 func (r RecipeModel) Update(recipe *Recipe) error {
@@ -428,8 +837,10 @@ func (r RecipeModel) Update(recipe *Recipe) error {
 	query := `
 		UPDATE recipes
 		SET name = $1, description = $2, notes = $3, source_url = $4,
-		    prep_time = $5, active_time = $6, servings = $7, version = version + 1
-		WHERE id = $8 AND version = $9
+		    prep_time = $5, active_time = $6, servings = $7, public = $8,
+		    calories = $9, protein_grams = $10, carbohydrate_grams = $11, fat_grams = $12,
+		    publish_at = $13, version = version + 1
+		WHERE id = $14 AND version = $15
 		RETURNING version`
 
 	// Convert data.Duration to PostgreSQL interval strings for database storage
@@ -441,9 +852,10 @@ func (r RecipeModel) Update(recipe *Recipe) error {
 		durationToInterval(time.Duration(recipe.PrepTime)),
 		durationToInterval(time.Duration(recipe.ActiveTime)),
 		nilIfZero(recipe.Servings),
-		recipe.ID,
-		recipe.Version,
+		recipe.Public,
 	}
+	args = append(args, nutritionArgs(recipe.Nutrition)...)
+	args = append(args, recipe.PublishAt, recipe.ID, recipe.Version)
 
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
@@ -613,9 +1025,47 @@ func (r RecipeModel) Delete(id int64) error {
 	return nil
 }
 
+// PublishDue makes public every private recipe whose scheduled publish time has
+// passed, returning their IDs. The schedule is cleared as each recipe is published.
+func (r RecipeModel) PublishDue() ([]int64, error) {
+	query := `
+		UPDATE recipes
+		SET public = TRUE, publish_at = NULL, version = version + 1
+		WHERE public = FALSE AND publish_at <= NOW() AND NOT unpublished_by_moderator
+		RETURNING id`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := r.DB.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := []int64{}
+
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return ids, nil
+}
+
 // GetAll retrieves a list of recipes with optional filtering, sorting, and pagination.
-// Returns a slice of recipes and pagination metadata.
-func (r RecipeModel) GetAll(name string, ingredients []string, equipment []string, prepTime Duration, activeTime Duration, filters Filters) ([]*Recipe, Metadata, error) {
+// Only public recipes and those created by or shared with viewerID are included, and
+// recipes using any of excludedIngredients are left out. A scope of ScopeMine narrows
+// this to the recipes viewerID created or favorited. Returns a slice of recipes and
+// pagination metadata.
+func (r RecipeModel) GetAll(viewerID int64, scope string, name string, ingredients []string, equipment []string, excludedIngredients []string, prepTime Duration, activeTime Duration, filters Filters) ([]*Recipe, Metadata, error) {
 	// Build the query with window function for total count
 	// Use a CTE to filter recipes, then join for display images
 	// Note: Go's time.Duration is int64 nanoseconds, but PostgreSQL prep_time/active_time
@@ -624,16 +1074,23 @@ func (r RecipeModel) GetAll(name string, ingredients []string, equipment []strin
 	query := `
 		WITH filtered_recipes AS (
 			SELECT DISTINCT r.id, r.name, r.description, r.prep_time, r.active_time,
-			       r.servings, r.user_id, r.created_at, r.version
+			       r.servings, r.user_id, r.public, r.publish_at, r.created_at, r.version
 			FROM recipes r
-			WHERE ($1 = '' OR r.name ILIKE '%' || $1 || '%')
+			WHERE (r.public = TRUE OR r.user_id = $4 OR EXISTS (
+				SELECT 1 FROM recipe_collaborators rc
+				WHERE rc.recipe_id = r.id AND rc.user_id = $4))
+			  AND ($1 = '' OR r.name ILIKE '%' || $1 || '%')
 			  AND ($2::double precision = 0 OR EXTRACT(EPOCH FROM r.prep_time) <= $2::double precision / 1000000000.0)
 			  AND ($3::double precision = 0 OR EXTRACT(EPOCH FROM r.active_time) <= $3::double precision / 1000000000.0)
 	`
 
 	// Build arguments slice - convert data.Duration to float64 nanoseconds for database query
-	args := []any{name, float64(time.Duration(prepTime)), float64(time.Duration(activeTime))}
-	argPos := 4
+	args := []any{name, float64(time.Duration(prepTime)), float64(time.Duration(activeTime)), viewerID}
+	argPos := 5
+
+	if scope == ScopeMine {
+		query += recipeScopeMine("$4")
+	}
 
 	// Add ingredients filter if provided
 	if len(ingredients) > 0 {
@@ -648,7 +1105,7 @@ func (r RecipeModel) GetAll(name string, ingredients []string, equipment []strin
 		for i, ing := range ingredients {
 			lowerIngredients[i] = "%" + ing + "%"
 		}
-		args = append(args, lowerIngredients)
+		args = append(args, pq.Array(lowerIngredients))
 		argPos++
 	}
 
@@ -665,25 +1122,144 @@ func (r RecipeModel) GetAll(name string, ingredients []string, equipment []strin
 		for i, eq := range equipment {
 			lowerEquipment[i] = "%" + eq + "%"
 		}
-		args = append(args, lowerEquipment)
+		args = append(args, pq.Array(lowerEquipment))
+		argPos++
+	}
+
+	// Exclude recipes containing any of the excluded ingredients (such as a household
+	// member's allergies). See excludedIngredientPattern() for how they are matched.
+	if len(excludedIngredients) > 0 {
+		query += ` AND r.id NOT IN (
+			SELECT ri.recipe_id
+			FROM recipe_ingredients ri
+			JOIN ingredients i ON ri.ingredient_id = i.id
+			WHERE i.name ILIKE ANY($` + fmt.Sprint(argPos) + `)
+		)`
+		patterns := make([]string, len(excludedIngredients))
+		for i, ing := range excludedIngredients {
+			patterns[i] = excludedIngredientPattern(ing)
+		}
+		args = append(args, pq.Array(patterns))
 		argPos++
 	}
 
 	// Close the CTE and build main query with COUNT(*) OVER()
-	// Extract prep_time and active_time as seconds (float) for easier scanning into Go
 	query += `
-		)
+		)` + recipeListSelect
+
+	// Add ORDER BY clause
+	query += recipeOrderBy(filters)
+
+	// Add LIMIT and OFFSET for pagination
+	query += fmt.Sprintf(" LIMIT $%d OFFSET $%d", argPos, argPos+1)
+	args = append(args, filters.PageSize, (filters.Page-1)*filters.PageSize)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := r.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer rows.Close()
+
+	return scanRecipeList(rows, filters)
+}
+
+// excludedIngredientPattern returns the ILIKE pattern used to find recipes containing
+// an excluded ingredient. Any ingredient whose name contains the term, ignoring case,
+// matches, with simple English plurals reduced to a common stem so that "peanuts" also
+// excludes "peanut" and "berries" excludes "berry". LIKE wildcards in the term are
+// escaped, so they match literally.
+func excludedIngredientPattern(term string) string {
+	term = strings.ToLower(strings.TrimSpace(term))
+
+	if len(term) > 3 && !strings.HasSuffix(term, "ss") {
+		switch {
+		case strings.HasSuffix(term, "ies"):
+			term = strings.TrimSuffix(term, "ies")
+		case strings.HasSuffix(term, "oes"), strings.HasSuffix(term, "ches"),
+			strings.HasSuffix(term, "shes"), strings.HasSuffix(term, "xes"):
+			term = strings.TrimSuffix(term, "es")
+		case strings.HasSuffix(term, "s"):
+			term = strings.TrimSuffix(term, "s")
+		}
+	}
+
+	term = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(term)
+
+	return "%" + term + "%"
+}
+
+// GetAllForIngredient retrieves a page of the recipes which use the given ingredient.
+// As with GetAll(), only public recipes and those created by or shared with viewerID
+// are included, further narrowed by scope.
+func (r RecipeModel) GetAllForIngredient(ingredientID int64, viewerID int64, scope string, filters Filters) ([]*Recipe, Metadata, error) {
+	query := `
+		WITH filtered_recipes AS (
+			SELECT r.id, r.name, r.description, r.prep_time, r.active_time,
+			       r.servings, r.user_id, r.public, r.publish_at, r.created_at, r.version
+			FROM recipes r
+			INNER JOIN recipe_ingredients ri ON ri.recipe_id = r.id
+			WHERE ri.ingredient_id = $1
+			  AND (r.public = TRUE OR r.user_id = $2 OR EXISTS (
+				SELECT 1 FROM recipe_collaborators rc
+				WHERE rc.recipe_id = r.id AND rc.user_id = $2))`
+
+	if scope == ScopeMine {
+		query += recipeScopeMine("$2")
+	}
+
+	query += `
+		)` + recipeListSelect + recipeOrderBy(filters) + `
+		LIMIT $3 OFFSET $4`
+
+	args := []any{ingredientID, viewerID, filters.limit(), filters.offset()}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := r.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer rows.Close()
+
+	return scanRecipeList(rows, filters)
+}
+
+// recipeScopeMine returns the condition which restricts a recipe list query to the
+// recipes created or favorited by the user whose ID is in the given placeholder. The
+// library is gathered with a UNION, rather than an OR across the two tables, so that
+// the recipes (user_id, name) index and the recipe_favorites primary key can each
+// drive their half of the lookup.
+func recipeScopeMine(viewerPlaceholder string) string {
+	return `
+			  AND r.id IN (
+				SELECT id FROM recipes WHERE user_id = ` + viewerPlaceholder + `
+				UNION
+				SELECT recipe_id FROM recipe_favorites WHERE user_id = ` + viewerPlaceholder + `)`
+}
+
+// recipeListSelect is the main query shared by the recipe list methods. It selects
+// summary recipes, with a COUNT(*) OVER() total, from a filtered_recipes CTE which the
+// caller must define. prep_time and active_time are extracted as seconds (float) for
+// easier scanning into Go.
+const recipeListSelect = `
 		SELECT COUNT(*) OVER() as total_records,
 		       fr.id, fr.name, fr.description,
 		       EXTRACT(EPOCH FROM fr.prep_time) as prep_time,
 		       EXTRACT(EPOCH FROM fr.active_time) as active_time,
-		       fr.servings, fr.created_at, fr.user_id, fr.version,
+		       fr.servings, fr.created_at, fr.user_id, fr.public, fr.publish_at, fr.version,
 		       ri.image_url as display_url
 		FROM filtered_recipes fr
 		LEFT JOIN recipe_images ri ON fr.id = ri.recipe_id AND ri.image_type = 'main'
 	`
 
-	// Add ORDER BY clause
+// recipeOrderBy returns the ORDER BY clause for a recipe list query, mapping the
+// requested sort onto the columns of the filtered_recipes (fr) CTE. Unknown sort values
+// fall back to ordering by ID.
+func recipeOrderBy(filters Filters) string {
 	sortColumn := filters.Sort
 	sortDirection := "ASC"
 	if len(sortColumn) > 0 && sortColumn[0] == '-' {
@@ -700,24 +1276,15 @@ func (r RecipeModel) GetAll(name string, ingredients []string, equipment []strin
 	}
 
 	if dbColumn, ok := sortColumns[sortColumn]; ok {
-		query += fmt.Sprintf(" ORDER BY %s %s", dbColumn, sortDirection)
-	} else {
-		query += " ORDER BY fr.id ASC"
+		return fmt.Sprintf(" ORDER BY %s %s", dbColumn, sortDirection)
 	}
 
-	// Add LIMIT and OFFSET for pagination
-	query += fmt.Sprintf(" LIMIT $%d OFFSET $%d", argPos, argPos+1)
-	args = append(args, filters.PageSize, (filters.Page-1)*filters.PageSize)
-
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
-	defer cancel()
-
-	rows, err := r.DB.QueryContext(ctx, query, args...)
-	if err != nil {
-		return nil, Metadata{}, err
-	}
-	defer rows.Close()
+	return " ORDER BY fr.id ASC"
+}
 
+// scanRecipeList reads the rows of a recipeListSelect query into summary recipes, and
+// calculates the pagination metadata from the total record count.
+func scanRecipeList(rows *sql.Rows, filters Filters) ([]*Recipe, Metadata, error) {
 	totalRecords := 0
 	recipes := []*Recipe{}
 
@@ -727,6 +1294,7 @@ func (r RecipeModel) GetAll(name string, ingredients []string, equipment []strin
 		var prepTimeSeconds, activeTimeSeconds sql.NullFloat64
 		var servings sql.NullInt32
 		var displayURL sql.NullString
+		var publishAt sql.NullTime
 
 		err := rows.Scan(
 			&totalRecords,
@@ -738,6 +1306,8 @@ func (r RecipeModel) GetAll(name string, ingredients []string, equipment []strin
 			&servings,
 			&recipe.CreatedAt,
 			&recipe.UserID,
+			&recipe.Public,
+			&publishAt,
 			&recipe.Version,
 			&displayURL,
 		)
@@ -763,11 +1333,14 @@ func (r RecipeModel) GetAll(name string, ingredients []string, equipment []strin
 		if displayURL.Valid {
 			recipe.DisplayURL = displayURL.String
 		}
+		if publishAt.Valid {
+			recipe.PublishAt = &publishAt.Time
+		}
 
 		recipes = append(recipes, &recipe)
 	}
 
-	if err = rows.Err(); err != nil {
+	if err := rows.Err(); err != nil {
 		return nil, Metadata{}, err
 	}
 
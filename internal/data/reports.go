@@ -0,0 +1,301 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"eatinn.dcashman.net/internal/validator"
+	"github.com/lib/pq"
+)
+
+var (
+	ErrDuplicateReport = errors.New("duplicate report")
+)
+
+// Report statuses. A report starts out open and is closed by a moderator either by
+// dismissing it, or by taking action against the reported recipe.
+const (
+	ReportStatusOpen      = "open"
+	ReportStatusDismissed = "dismissed"
+	ReportStatusActioned  = "actioned"
+)
+
+// Report records a user flagging a public recipe as inappropriate.
+type Report struct {
+	ID         int64      `json:"id"`
+	CreatedAt  time.Time  `json:"created_at"`
+	RecipeID   int64      `json:"recipe_id"`
+	UserID     int64      `json:"user_id"`
+	Reason     string     `json:"reason"`
+	Status     string     `json:"status"`
+	ResolvedBy *int64     `json:"resolved_by,omitempty"`
+	ResolvedAt *time.Time `json:"resolved_at,omitempty"`
+	Version    int32      `json:"version"`
+}
+
+func ValidateReport(v *validator.Validator, report *Report) {
+	v.Check(report.Reason != "", "reason", "must be provided")
+	v.Check(len(report.Reason) <= 1000, "reason", "must not be more than 1000 bytes long")
+}
+
+// Define a ReportModel struct type which wraps a sql.DB connection pool.
+type ReportModel struct {
+	DB *sql.DB
+}
+
+// Insert adds a new open report. Each user may only report a given recipe once, so a
+// violation of the unique constraint is returned as ErrDuplicateReport.
+func (m ReportModel) Insert(report *Report) error {
+	query := `
+		INSERT INTO recipe_reports (recipe_id, user_id, reason)
+		VALUES ($1, $2, $3)
+		RETURNING id, created_at, status, version`
+
+	args := []any{report.RecipeID, report.UserID, report.Reason}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&report.ID, &report.CreatedAt, &report.Status, &report.Version)
+	if err != nil {
+		var pqErr *pq.Error
+		switch {
+		case errors.As(err, &pqErr) && pqErr.Code == "23505" && pqErr.Constraint == "recipe_reports_recipe_user_key":
+			return ErrDuplicateReport
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Get fetches a specific report by ID.
+func (m ReportModel) Get(id int64) (*Report, error) {
+	if id < 1 {
+		return nil, ErrRecordNotFound
+	}
+
+	query := `
+		SELECT id, created_at, recipe_id, user_id, reason, status, resolved_by, resolved_at, version
+		FROM recipe_reports
+		WHERE id = $1`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	report, err := scanReport(m.DB.QueryRowContext(ctx, query, id))
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return report, nil
+}
+
+// GetAll returns a page of reports, optionally restricted to a single status and/or
+// recipe. Passing an empty status or a zero recipe ID disables that filter.
+func (m ReportModel) GetAll(status string, recipeID int64, filters Filters) ([]*Report, Metadata, error) {
+	query := `
+		SELECT COUNT(*) OVER(), id, created_at, recipe_id, user_id, reason, status, resolved_by, resolved_at, version
+		FROM recipe_reports
+		WHERE ($1::text = '' OR status = $1)
+		  AND ($2::bigint = 0 OR recipe_id = $2)
+		ORDER BY created_at ASC, id ASC
+		LIMIT $3 OFFSET $4`
+
+	args := []any{status, recipeID, filters.limit(), filters.offset()}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer rows.Close()
+
+	totalRecords := 0
+	reports := []*Report{}
+
+	for rows.Next() {
+		var report Report
+		var resolvedBy sql.NullInt64
+		var resolvedAt sql.NullTime
+
+		err := rows.Scan(
+			&totalRecords,
+			&report.ID,
+			&report.CreatedAt,
+			&report.RecipeID,
+			&report.UserID,
+			&report.Reason,
+			&report.Status,
+			&resolvedBy,
+			&resolvedAt,
+			&report.Version,
+		)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+
+		if resolvedBy.Valid {
+			report.ResolvedBy = &resolvedBy.Int64
+		}
+		if resolvedAt.Valid {
+			report.ResolvedAt = &resolvedAt.Time
+		}
+
+		reports = append(reports, &report)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	metadata := calculateMetadata(totalRecords, filters.Page, filters.PageSize)
+
+	return reports, metadata, nil
+}
+
+// Resolve closes an open report with the given status on behalf of a moderator. It
+// uses optimistic locking via the version field, returning ErrEditConflict if the
+// report has been changed since it was read.
+func (m ReportModel) Resolve(report *Report, status string, moderatorID int64) error {
+	query := `
+		UPDATE recipe_reports
+		SET status = $1, resolved_by = $2, resolved_at = NOW(), version = version + 1
+		WHERE id = $3 AND version = $4
+		RETURNING status, resolved_by, resolved_at, version`
+
+	args := []any{status, moderatorID, report.ID, report.Version}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var resolvedBy int64
+	var resolvedAt time.Time
+
+	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&report.Status, &resolvedBy, &resolvedAt, &report.Version)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrEditConflict
+		default:
+			return err
+		}
+	}
+
+	report.ResolvedBy = &resolvedBy
+	report.ResolvedAt = &resolvedAt
+
+	return nil
+}
+
+// UnpublishRecipe makes a reported recipe private (cancelling any scheduled publication)
+// and marks every open report against it as actioned, in a single transaction. The
+// recipe is flagged so that its creator can't make it public again until a moderator
+// restores it.
+func (m ReportModel) UnpublishRecipe(recipeID int64, moderatorID int64) error {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := tx.ExecContext(ctx, `
+		UPDATE recipes
+		SET public = FALSE, publish_at = NULL, unpublished_by_moderator = TRUE, version = version + 1
+		WHERE id = $1
+	`, recipeID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		UPDATE recipe_reports
+		SET status = $1, resolved_by = $2, resolved_at = NOW(), version = version + 1
+		WHERE recipe_id = $3 AND status = $4
+	`, ReportStatusActioned, moderatorID, recipeID, ReportStatusOpen)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// RestoreRecipe lifts a moderator's unpublishing of a recipe, allowing its creator to
+// make it public again. The recipe itself stays private.
+func (m ReportModel) RestoreRecipe(recipeID int64) error {
+	query := `
+		UPDATE recipes
+		SET unpublished_by_moderator = FALSE, version = version + 1
+		WHERE id = $1 AND unpublished_by_moderator`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, recipeID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
+
+func scanReport(row *sql.Row) (*Report, error) {
+	var report Report
+	var resolvedBy sql.NullInt64
+	var resolvedAt sql.NullTime
+
+	err := row.Scan(
+		&report.ID,
+		&report.CreatedAt,
+		&report.RecipeID,
+		&report.UserID,
+		&report.Reason,
+		&report.Status,
+		&resolvedBy,
+		&resolvedAt,
+		&report.Version,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if resolvedBy.Valid {
+		report.ResolvedBy = &resolvedBy.Int64
+	}
+	if resolvedAt.Valid {
+		report.ResolvedAt = &resolvedAt.Time
+	}
+
+	return &report, nil
+}
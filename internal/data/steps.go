@@ -0,0 +1,238 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// KitchenStep is a compact, self-contained view of a single instruction step, for
+// clients such as kitchen displays which show a recipe one step at a time. Number is
+// the step's position in the recipe, starting at 1.
+type KitchenStep struct {
+	RecipeID    int64             `json:"recipe_id"`
+	RecipeName  string            `json:"recipe_name"`
+	Number      int               `json:"number"`
+	TotalSteps  int               `json:"total_steps"`
+	Text        string            `json:"text"`
+	Notes       string            `json:"notes,omitempty"`
+	ImageURLs   []string          `json:"image_urls,omitempty"`
+	Timers      []StepTimer       `json:"timers"`
+	Ingredients []IngredientEntry `json:"ingredients"`
+}
+
+// StepTimer is a timer suggested by a duration mentioned in a step's text, such as
+// "simmer for 10-15 minutes". For a range, Duration is the lower bound and MaxDuration
+// the upper.
+type StepTimer struct {
+	Label       string   `json:"label"`
+	Duration    Duration `json:"duration"`
+	MaxDuration Duration `json:"max_duration,omitempty"`
+}
+
+// stepDurationRX matches durations such as "45 seconds", "10 mins", "1.5 hours" and
+// ranges such as "20-25 minutes" or "2 to 3 hrs".
+var stepDurationRX = regexp.MustCompile(`(?i)\b(\d+(?:\.\d+)?)(?:\s*(?:-|–|to)\s*(\d+(?:\.\d+)?))?\s*(seconds?|secs?|minutes?|mins?|hours?|hrs?)\b`)
+
+// GetStepRecipe fetches only the fields of a recipe needed to check whether a user may
+// see its steps, and to tell whether a cached step is still current: its ID, name,
+// creator, public flag, version and collaborators.
+func (r RecipeModel) GetStepRecipe(id int64) (*Recipe, error) {
+	query := `
+		SELECT id, name, user_id, public, version
+		FROM recipes
+		WHERE id = $1`
+
+	var recipe Recipe
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := r.DB.QueryRowContext(ctx, query, id).Scan(
+		&recipe.ID,
+		&recipe.Name,
+		&recipe.UserID,
+		&recipe.Public,
+		&recipe.Version,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	access, err := getCollaboratorAccess(ctx, r.DB, []int64{id})
+	if err != nil {
+		return nil, err
+	}
+	recipe.collaborators = access[id]
+
+	return &recipe, nil
+}
+
+// GetKitchenStep fetches the nth step (counting from 1) of a recipe returned by
+// GetStepRecipe(), reading only that step, its images and the recipe's ingredients. It
+// returns ErrRecordNotFound if the recipe has no such step.
+func (r RecipeModel) GetKitchenStep(recipe *Recipe, n int) (*KitchenStep, error) {
+	if n < 1 {
+		return nil, ErrRecordNotFound
+	}
+
+	// Step numbers aren't guaranteed to be contiguous, so the nth step is found by its
+	// position rather than its step_number.
+	stepQuery := `
+		SELECT id, instruction, notes, count(*) OVER ()
+		FROM recipe_instructions
+		WHERE recipe_id = $1
+		ORDER BY step_number
+		OFFSET $2 LIMIT 1`
+
+	step := KitchenStep{
+		RecipeID:   recipe.ID,
+		RecipeName: recipe.Name,
+		Number:     n,
+	}
+
+	var instructionID int64
+	var notes sql.NullString
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := r.DB.QueryRowContext(ctx, stepQuery, recipe.ID, n-1).Scan(
+		&instructionID,
+		&step.Text,
+		&notes,
+		&step.TotalSteps,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+	if notes.Valid {
+		step.Notes = notes.String
+	}
+
+	imageQuery := `
+		SELECT ri.image_url
+		FROM recipe_images ri
+		INNER JOIN recipe_instruction_images rii ON ri.id = rii.image_id
+		WHERE rii.instruction_id = $1
+		ORDER BY ri.id`
+
+	imageRows, err := r.DB.QueryContext(ctx, imageQuery, instructionID)
+	if err != nil {
+		return nil, err
+	}
+	defer imageRows.Close()
+
+	for imageRows.Next() {
+		var imageURL string
+		err := imageRows.Scan(&imageURL)
+		if err != nil {
+			return nil, err
+		}
+		step.ImageURLs = append(step.ImageURLs, imageURL)
+	}
+
+	if err = imageRows.Err(); err != nil {
+		return nil, err
+	}
+
+	ingredientsQuery := `
+		SELECT i.id, i.name, ri.quantity, ri.unit, ri.optional
+		FROM ingredients i
+		INNER JOIN recipe_ingredients ri ON i.id = ri.ingredient_id
+		WHERE ri.recipe_id = $1
+		ORDER BY i.name`
+
+	ingredientRows, err := r.DB.QueryContext(ctx, ingredientsQuery, recipe.ID)
+	if err != nil {
+		return nil, err
+	}
+	defer ingredientRows.Close()
+
+	ingredients := []IngredientEntry{}
+	for ingredientRows.Next() {
+		var ingredient IngredientEntry
+		err := ingredientRows.Scan(
+			&ingredient.ID,
+			&ingredient.Ingredient,
+			&ingredient.Amount,
+			&ingredient.Unit,
+			&ingredient.Optional,
+		)
+		if err != nil {
+			return nil, err
+		}
+		ingredients = append(ingredients, ingredient)
+	}
+
+	if err = ingredientRows.Err(); err != nil {
+		return nil, err
+	}
+
+	step.Timers = stepTimers(step.Text)
+	step.Ingredients = stepIngredients(step.Text, ingredients)
+
+	return &step, nil
+}
+
+// stepTimers extracts the durations mentioned in a step's text.
+func stepTimers(text string) []StepTimer {
+	timers := []StepTimer{}
+
+	for _, match := range stepDurationRX.FindAllStringSubmatch(text, -1) {
+		var unit time.Duration
+		switch strings.ToLower(match[3])[0] {
+		case 's':
+			unit = time.Second
+		case 'm':
+			unit = time.Minute
+		default:
+			unit = time.Hour
+		}
+
+		timer := StepTimer{Label: match[0], Duration: parseStepDuration(match[1], unit)}
+		if match[2] != "" {
+			timer.MaxDuration = parseStepDuration(match[2], unit)
+		}
+
+		timers = append(timers, timer)
+	}
+
+	return timers
+}
+
+func parseStepDuration(amount string, unit time.Duration) Duration {
+	// The regular expression only matches valid numbers, so the error can be ignored.
+	f, _ := strconv.ParseFloat(amount, 64)
+	return Duration(time.Duration(f * float64(unit)))
+}
+
+// stepIngredients returns the recipe's ingredients which are mentioned by name in the
+// step's text.
+func stepIngredients(text string, ingredients []IngredientEntry) []IngredientEntry {
+	text = strings.ToLower(text)
+	used := []IngredientEntry{}
+
+	for _, ingredient := range ingredients {
+		name := strings.ToLower(strings.TrimSpace(ingredient.Ingredient))
+		if name != "" && strings.Contains(text, name) {
+			used = append(used, ingredient)
+		}
+	}
+
+	return used
+}
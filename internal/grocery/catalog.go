@@ -0,0 +1,132 @@
+package grocery
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Catalog is a Provider backed by a product list loaded from a JSON file. Carts are
+// exported as a cart permalink, which many online store platforms support: the
+// catalog's cart_url contains an {items} placeholder that is replaced with a
+// comma-separated list of SKU:quantity pairs, for example
+// "https://shop.example.com/cart/{items}".
+type Catalog struct {
+	name     string
+	cartURL  string
+	products []Product
+	bySKU    map[string]Product
+}
+
+type catalogFile struct {
+	Name     string    `json:"name"`
+	CartURL  string    `json:"cart_url"`
+	Products []Product `json:"products"`
+}
+
+// LoadCatalog reads a catalog from a JSON file with name, cart_url and products keys.
+func LoadCatalog(path string) (*Catalog, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var f catalogFile
+	err = json.Unmarshal(b, &f)
+	if err != nil {
+		return nil, fmt.Errorf("grocery catalog %s: %w", path, err)
+	}
+
+	switch {
+	case f.Name == "":
+		return nil, fmt.Errorf("grocery catalog %s: name must be provided", path)
+	case !strings.Contains(f.CartURL, "{items}"):
+		return nil, fmt.Errorf("grocery catalog %s: cart_url must contain {items}", path)
+	}
+
+	c := &Catalog{
+		name:     f.Name,
+		cartURL:  f.CartURL,
+		products: f.Products,
+		bySKU:    make(map[string]Product, len(f.Products)),
+	}
+
+	for _, product := range f.Products {
+		if product.SKU == "" || product.Name == "" {
+			return nil, fmt.Errorf("grocery catalog %s: every product must have a sku and name", path)
+		}
+		if _, exists := c.bySKU[product.SKU]; exists {
+			return nil, fmt.Errorf("grocery catalog %s: duplicate sku %q", path, product.SKU)
+		}
+		c.bySKU[product.SKU] = product
+	}
+
+	return c, nil
+}
+
+func (c *Catalog) Name() string {
+	return c.name
+}
+
+// Search returns the products whose name contains the ingredient, ignoring case. Exact
+// matches come first, followed by the products with the shortest names, which tend to
+// be the plainest versions of the ingredient.
+func (c *Catalog) Search(ctx context.Context, ingredient string, limit int) ([]Product, error) {
+	ingredient = strings.ToLower(strings.TrimSpace(ingredient))
+	if ingredient == "" {
+		return []Product{}, nil
+	}
+
+	products := []Product{}
+	for _, product := range c.products {
+		if strings.Contains(strings.ToLower(product.Name), ingredient) {
+			products = append(products, product)
+		}
+	}
+
+	sort.SliceStable(products, func(i, j int) bool {
+		iExact := strings.EqualFold(products[i].Name, ingredient)
+		jExact := strings.EqualFold(products[j].Name, ingredient)
+		if iExact != jExact {
+			return iExact
+		}
+		return len(products[i].Name) < len(products[j].Name)
+	})
+
+	if len(products) > limit {
+		products = products[:limit]
+	}
+
+	return products, nil
+}
+
+// ExportCart builds a cart permalink for the items. Nothing is sent to the store until
+// the user follows the link.
+func (c *Catalog) ExportCart(ctx context.Context, items []CartItem) (*Cart, error) {
+	cart := &Cart{Provider: c.name, Lines: []CartLine{}}
+	pairs := make([]string, 0, len(items))
+
+	for _, item := range items {
+		product, ok := c.bySKU[item.SKU]
+		if !ok {
+			return nil, fmt.Errorf("%w: %s", ErrUnknownProduct, item.SKU)
+		}
+		if item.Quantity < 1 {
+			return nil, errors.New("quantity must be at least 1")
+		}
+
+		cart.Lines = append(cart.Lines, CartLine{Product: product, Quantity: item.Quantity})
+		cart.TotalCents += product.PriceCents * int64(item.Quantity)
+		pairs = append(pairs, url.PathEscape(item.SKU)+":"+strconv.Itoa(item.Quantity))
+	}
+
+	cart.CheckoutURL = strings.Replace(c.cartURL, "{items}", strings.Join(pairs, ","), 1)
+
+	return cart, nil
+}
@@ -0,0 +1,126 @@
+// Package grocery matches ingredients to products sold by grocery stores and exports
+// carts to them. Each store is integrated through a Provider, so that new stores can be
+// supported without changes to the rest of the application.
+package grocery
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sort"
+)
+
+// ErrUnknownProduct is returned by Provider.ExportCart() when a cart contains a SKU
+// which the provider doesn't sell.
+var ErrUnknownProduct = errors.New("unknown product")
+
+// Product is an item sold by a store. Prices are in the smallest unit of the currency.
+type Product struct {
+	SKU        string `json:"sku"`
+	Name       string `json:"name"`
+	Brand      string `json:"brand,omitempty"`
+	Size       string `json:"size,omitempty"`
+	PriceCents int64  `json:"price_cents"`
+	Currency   string `json:"currency"`
+	URL        string `json:"url,omitempty"`
+}
+
+// CartItem is a quantity of a product to add to a cart.
+type CartItem struct {
+	SKU      string `json:"sku"`
+	Quantity int    `json:"quantity"`
+}
+
+// CartLine is a product in an exported cart.
+type CartLine struct {
+	Product  Product `json:"product"`
+	Quantity int     `json:"quantity"`
+}
+
+// Cart is the result of exporting items to a store. CheckoutURL is where the user can
+// review the cart and pay.
+type Cart struct {
+	Provider    string     `json:"provider"`
+	Lines       []CartLine `json:"lines"`
+	TotalCents  int64      `json:"total_cents"`
+	CheckoutURL string     `json:"checkout_url"`
+}
+
+// Match holds the product suggestions for one ingredient, best match first.
+type Match struct {
+	Ingredient string    `json:"ingredient"`
+	Products   []Product `json:"products"`
+}
+
+// Provider is implemented by each supported grocery store integration.
+type Provider interface {
+	// Name identifies the provider in API requests.
+	Name() string
+
+	// Search returns up to limit products which could be bought for the ingredient,
+	// best match first.
+	Search(ctx context.Context, ingredient string, limit int) ([]Product, error)
+
+	// ExportCart creates a cart containing the items on the store.
+	ExportCart(ctx context.Context, items []CartItem) (*Cart, error)
+}
+
+// Registry holds the configured providers, keyed by name.
+type Registry struct {
+	providers map[string]Provider
+}
+
+// NewRegistry returns a Registry containing the given providers. It returns an error if
+// two of them have the same name.
+func NewRegistry(providers ...Provider) (*Registry, error) {
+	r := &Registry{providers: make(map[string]Provider, len(providers))}
+
+	for _, p := range providers {
+		if _, exists := r.providers[p.Name()]; exists {
+			return nil, errors.New("duplicate grocery provider " + p.Name())
+		}
+		r.providers[p.Name()] = p
+	}
+
+	return r, nil
+}
+
+// Get returns the named provider.
+func (r *Registry) Get(name string) (Provider, bool) {
+	p, ok := r.providers[name]
+	return p, ok
+}
+
+// Names returns the names of the registered providers, in alphabetical order.
+func (r *Registry) Names() []string {
+	names := make([]string, 0, len(r.providers))
+	for name := range r.providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// MatchIngredients searches the provider for each ingredient in turn. Duplicate
+// ingredients are only searched for once.
+func MatchIngredients(ctx context.Context, p Provider, ingredients []string, limit int) ([]Match, error) {
+	matches := []Match{}
+	seen := []string{}
+
+	for _, ingredient := range ingredients {
+		if slices.Contains(seen, ingredient) {
+			continue
+		}
+		seen = append(seen, ingredient)
+
+		products, err := p.Search(ctx, ingredient, limit)
+		if err != nil {
+			return nil, err
+		}
+
+		matches = append(matches, Match{Ingredient: ingredient, Products: products})
+	}
+
+	return matches, nil
+}
@@ -0,0 +1,335 @@
+package grocery
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// shopifyAPIVersion is the Storefront API version requests are made against. Shopify
+// supports each version for at least a year after its release.
+const shopifyAPIVersion = "2025-01"
+
+// Shopify is a Provider backed by the Storefront API of a store running on Shopify,
+// which many independent grocers and farm shops use. Products are searched for live,
+// and carts are created on the store, whose checkout page the user is then sent to.
+//
+// SKUs are Shopify product variant IDs (for example "gid://shopify/ProductVariant/1"),
+// since those are what carts are built from. Each product is represented by its first
+// variant.
+type Shopify struct {
+	name     string
+	endpoint string
+	token    string
+	client   *http.Client
+}
+
+type shopifyFile struct {
+	Name                  string `json:"name"`
+	Domain                string `json:"domain"`
+	StorefrontAccessToken string `json:"storefront_access_token"`
+}
+
+// LoadShopify reads a Shopify store's settings from a JSON file with name, domain (such
+// as "shop.example.com" or "example.myshopify.com") and storefront_access_token keys.
+// The token is created in the store's admin with the unauthenticated_read_product_listings
+// and unauthenticated_write_checkouts scopes.
+func LoadShopify(path string) (*Shopify, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var f shopifyFile
+	err = json.Unmarshal(b, &f)
+	if err != nil {
+		return nil, fmt.Errorf("grocery shopify store %s: %w", path, err)
+	}
+
+	switch {
+	case f.Name == "":
+		return nil, fmt.Errorf("grocery shopify store %s: name must be provided", path)
+	case f.Domain == "" || strings.ContainsAny(f.Domain, "/:"):
+		return nil, fmt.Errorf("grocery shopify store %s: domain must be a host name", path)
+	case f.StorefrontAccessToken == "":
+		return nil, fmt.Errorf("grocery shopify store %s: storefront_access_token must be provided", path)
+	}
+
+	s := &Shopify{
+		name:     f.Name,
+		endpoint: fmt.Sprintf("https://%s/api/%s/graphql.json", f.Domain, shopifyAPIVersion),
+		token:    f.StorefrontAccessToken,
+		client:   &http.Client{Timeout: 10 * time.Second},
+	}
+
+	return s, nil
+}
+
+func (s *Shopify) Name() string {
+	return s.name
+}
+
+// shopifyMoney and shopifyVariant mirror the parts of the Storefront API schema which
+// are read.
+type shopifyMoney struct {
+	Amount       string `json:"amount"`
+	CurrencyCode string `json:"currencyCode"`
+}
+
+type shopifyVariant struct {
+	ID      string       `json:"id"`
+	Title   string       `json:"title"`
+	Price   shopifyMoney `json:"price"`
+	Product struct {
+		Title          string `json:"title"`
+		Vendor         string `json:"vendor"`
+		OnlineStoreURL string `json:"onlineStoreUrl"`
+	} `json:"product"`
+}
+
+const shopifySearchQuery = `
+	query Search($query: String!, $first: Int!) {
+		products(first: $first, query: $query, sortKey: RELEVANCE) {
+			nodes {
+				variants(first: 1) {
+					nodes {
+						id
+						title
+						price { amount currencyCode }
+						product { title vendor onlineStoreUrl }
+					}
+				}
+			}
+		}
+	}`
+
+// Search returns the store's products matching the ingredient which are available for
+// sale, in the order of relevance given by the store.
+func (s *Shopify) Search(ctx context.Context, ingredient string, limit int) ([]Product, error) {
+	ingredient = strings.TrimSpace(ingredient)
+	if ingredient == "" {
+		return []Product{}, nil
+	}
+
+	// Quote the ingredient, so that it is treated as a phrase rather than as Shopify
+	// search syntax.
+	query := `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(ingredient) + `" available_for_sale:true`
+
+	var data struct {
+		Products struct {
+			Nodes []struct {
+				Variants struct {
+					Nodes []shopifyVariant `json:"nodes"`
+				} `json:"variants"`
+			} `json:"nodes"`
+		} `json:"products"`
+	}
+
+	err := s.do(ctx, shopifySearchQuery, map[string]any{"query": query, "first": limit}, &data)
+	if err != nil {
+		return nil, err
+	}
+
+	products := []Product{}
+	for _, node := range data.Products.Nodes {
+		if len(node.Variants.Nodes) == 0 {
+			continue
+		}
+
+		product, err := node.Variants.Nodes[0].product()
+		if err != nil {
+			return nil, err
+		}
+		products = append(products, product)
+	}
+
+	return products, nil
+}
+
+const shopifyCartCreateMutation = `
+	mutation CartCreate($input: CartInput!) {
+		cartCreate(input: $input) {
+			cart {
+				checkoutUrl
+				cost { subtotalAmount { amount currencyCode } }
+				lines(first: 250) {
+					nodes {
+						quantity
+						merchandise {
+							... on ProductVariant {
+								id
+								title
+								price { amount currencyCode }
+								product { title vendor onlineStoreUrl }
+							}
+						}
+					}
+				}
+			}
+			userErrors { code field message }
+		}
+	}`
+
+// ExportCart creates a cart on the store containing the items. The cart's total is the
+// subtotal calculated by the store, before taxes and shipping.
+func (s *Shopify) ExportCart(ctx context.Context, items []CartItem) (*Cart, error) {
+	lines := make([]map[string]any, 0, len(items))
+	for _, item := range items {
+		if !strings.HasPrefix(item.SKU, "gid://shopify/ProductVariant/") {
+			return nil, fmt.Errorf("%w: %s", ErrUnknownProduct, item.SKU)
+		}
+		if item.Quantity < 1 {
+			return nil, errors.New("quantity must be at least 1")
+		}
+		lines = append(lines, map[string]any{"merchandiseId": item.SKU, "quantity": item.Quantity})
+	}
+
+	var data struct {
+		CartCreate struct {
+			Cart *struct {
+				CheckoutURL string `json:"checkoutUrl"`
+				Cost        struct {
+					SubtotalAmount shopifyMoney `json:"subtotalAmount"`
+				} `json:"cost"`
+				Lines struct {
+					Nodes []struct {
+						Quantity    int            `json:"quantity"`
+						Merchandise shopifyVariant `json:"merchandise"`
+					} `json:"nodes"`
+				} `json:"lines"`
+			} `json:"cart"`
+			UserErrors []struct {
+				Code    string `json:"code"`
+				Message string `json:"message"`
+			} `json:"userErrors"`
+		} `json:"cartCreate"`
+	}
+
+	err := s.do(ctx, shopifyCartCreateMutation, map[string]any{"input": map[string]any{"lines": lines}}, &data)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, userError := range data.CartCreate.UserErrors {
+		switch userError.Code {
+		case "INVALID_MERCHANDISE_LINE", "MERCHANDISE_NOT_FOUND":
+			return nil, fmt.Errorf("%w: %s", ErrUnknownProduct, userError.Message)
+		default:
+			return nil, fmt.Errorf("shopify %s: %s", userError.Code, userError.Message)
+		}
+	}
+
+	if data.CartCreate.Cart == nil {
+		return nil, errors.New("shopify: no cart was created")
+	}
+
+	cart := &Cart{
+		Provider:    s.name,
+		Lines:       []CartLine{},
+		CheckoutURL: data.CartCreate.Cart.CheckoutURL,
+	}
+
+	for _, line := range data.CartCreate.Cart.Lines.Nodes {
+		product, err := line.Merchandise.product()
+		if err != nil {
+			return nil, err
+		}
+		cart.Lines = append(cart.Lines, CartLine{Product: product, Quantity: line.Quantity})
+	}
+
+	cart.TotalCents, err = shopifyCents(data.CartCreate.Cart.Cost.SubtotalAmount.Amount)
+	if err != nil {
+		return nil, err
+	}
+
+	return cart, nil
+}
+
+// do sends a GraphQL request to the Storefront API and decodes the data in the response
+// into dst.
+func (s *Shopify) do(ctx context.Context, query string, variables map[string]any, dst any) error {
+	body, err := json.Marshal(map[string]any{"query": query, "variables": variables})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Shopify-Storefront-Access-Token", s.token)
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("shopify: unexpected status %s", resp.Status)
+	}
+
+	var result struct {
+		Data   json.RawMessage `json:"data"`
+		Errors []struct {
+			Message string `json:"message"`
+		} `json:"errors"`
+	}
+
+	err = json.NewDecoder(io.LimitReader(resp.Body, 5_000_000)).Decode(&result)
+	if err != nil {
+		return fmt.Errorf("shopify: %w", err)
+	}
+
+	if len(result.Errors) > 0 {
+		return fmt.Errorf("shopify: %s", result.Errors[0].Message)
+	}
+
+	return json.Unmarshal(result.Data, dst)
+}
+
+// product converts a variant into a Product. The variant's title is used as the size,
+// unless the product only has the default variant.
+func (v shopifyVariant) product() (Product, error) {
+	cents, err := shopifyCents(v.Price.Amount)
+	if err != nil {
+		return Product{}, err
+	}
+
+	product := Product{
+		SKU:        v.ID,
+		Name:       v.Product.Title,
+		Brand:      v.Product.Vendor,
+		PriceCents: cents,
+		Currency:   v.Price.CurrencyCode,
+		URL:        v.Product.OnlineStoreURL,
+	}
+	if v.Title != "Default Title" {
+		product.Size = v.Title
+	}
+
+	return product, nil
+}
+
+// shopifyCents converts a decimal amount, such as "4.5", into hundredths of the
+// currency unit.
+func shopifyCents(amount string) (int64, error) {
+	whole, fraction, _ := strings.Cut(amount, ".")
+	fraction = (fraction + "00")[:2]
+
+	cents, err := strconv.ParseInt(whole+fraction, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("shopify: invalid amount %q", amount)
+	}
+
+	return cents, nil
+}
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_recipes_public;
+
+ALTER TABLE recipes DROP COLUMN IF EXISTS public;
\ No newline at end of file
@@ -0,0 +1,6 @@
+-- Recipes were readable by everyone before visibility was introduced, so existing
+-- recipes stay public. Only recipes created from now on default to private.
+ALTER TABLE recipes ADD COLUMN IF NOT EXISTS public boolean NOT NULL DEFAULT TRUE;
+ALTER TABLE recipes ALTER COLUMN public SET DEFAULT FALSE;
+
+CREATE INDEX IF NOT EXISTS idx_recipes_public ON recipes(public);
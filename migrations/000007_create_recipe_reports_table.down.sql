@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_recipe_reports_status;
+DROP TABLE IF EXISTS recipe_reports;
\ No newline at end of file
@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS recipe_reports (
+    id bigserial PRIMARY KEY,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    recipe_id bigint NOT NULL REFERENCES recipes(id) ON DELETE CASCADE,
+    user_id bigint NOT NULL REFERENCES users ON DELETE CASCADE,
+    reason text NOT NULL,
+    status text NOT NULL DEFAULT 'open',
+    resolved_by bigint REFERENCES users ON DELETE SET NULL,
+    resolved_at timestamp(0) with time zone,
+    version integer NOT NULL DEFAULT 1,
+    CONSTRAINT recipe_reports_status_check CHECK (status IN ('open', 'dismissed', 'actioned')),
+    CONSTRAINT recipe_reports_recipe_user_key UNIQUE (recipe_id, user_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_recipe_reports_status ON recipe_reports(status);
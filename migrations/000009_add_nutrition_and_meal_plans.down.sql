@@ -0,0 +1,8 @@
+DROP INDEX IF EXISTS idx_meal_plan_entries_user_planned_for;
+DROP TABLE IF EXISTS meal_plan_entries;
+DROP TABLE IF EXISTS nutrition_goals;
+
+ALTER TABLE recipes DROP COLUMN IF EXISTS fat_grams;
+ALTER TABLE recipes DROP COLUMN IF EXISTS carbohydrate_grams;
+ALTER TABLE recipes DROP COLUMN IF EXISTS protein_grams;
+ALTER TABLE recipes DROP COLUMN IF EXISTS calories;
\ No newline at end of file
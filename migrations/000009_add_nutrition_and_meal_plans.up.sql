@@ -0,0 +1,27 @@
+ALTER TABLE recipes ADD COLUMN IF NOT EXISTS calories double precision;
+ALTER TABLE recipes ADD COLUMN IF NOT EXISTS protein_grams double precision;
+ALTER TABLE recipes ADD COLUMN IF NOT EXISTS carbohydrate_grams double precision;
+ALTER TABLE recipes ADD COLUMN IF NOT EXISTS fat_grams double precision;
+
+CREATE TABLE IF NOT EXISTS nutrition_goals (
+    user_id bigint PRIMARY KEY REFERENCES users ON DELETE CASCADE,
+    calories double precision NOT NULL DEFAULT 0,
+    protein_grams double precision NOT NULL DEFAULT 0,
+    carbohydrate_grams double precision NOT NULL DEFAULT 0,
+    fat_grams double precision NOT NULL DEFAULT 0,
+    version integer NOT NULL DEFAULT 1
+);
+
+CREATE TABLE IF NOT EXISTS meal_plan_entries (
+    id bigserial PRIMARY KEY,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    user_id bigint NOT NULL REFERENCES users ON DELETE CASCADE,
+    recipe_id bigint NOT NULL REFERENCES recipes(id) ON DELETE CASCADE,
+    planned_for date NOT NULL,
+    meal text NOT NULL,
+    servings double precision NOT NULL DEFAULT 1,
+    version integer NOT NULL DEFAULT 1,
+    CONSTRAINT meal_plan_entries_servings_check CHECK (servings > 0)
+);
+
+CREATE INDEX IF NOT EXISTS idx_meal_plan_entries_user_planned_for ON meal_plan_entries(user_id, planned_for);
@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS idx_recipe_images_recipe_id_position;
+
+-- PostgreSQL can't drop a value from an enum, so the 'gallery' value remains but is
+-- no longer used.
+DELETE FROM recipe_images WHERE image_type = 'gallery';
+
+ALTER TABLE recipe_images DROP COLUMN IF EXISTS position;
\ No newline at end of file
@@ -0,0 +1,8 @@
+-- ALTER TYPE ... ADD VALUE can only run inside a transaction block, as migrations are,
+-- on PostgreSQL 12 or later. Even then, the new value can't be used until the
+-- transaction has committed, so nothing below may refer to 'gallery'.
+ALTER TYPE recipe_image_type ADD VALUE IF NOT EXISTS 'gallery';
+
+ALTER TABLE recipe_images ADD COLUMN IF NOT EXISTS position integer;
+
+CREATE INDEX IF NOT EXISTS idx_recipe_images_recipe_id_position ON recipe_images(recipe_id, position);
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_household_members_user_id;
+DROP TABLE IF EXISTS household_members;
\ No newline at end of file
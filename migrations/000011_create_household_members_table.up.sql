@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS household_members (
+    id bigserial PRIMARY KEY,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    user_id bigint NOT NULL REFERENCES users ON DELETE CASCADE,
+    name text NOT NULL,
+    allergies text[] NOT NULL DEFAULT '{}',
+    dislikes text[] NOT NULL DEFAULT '{}',
+    version integer NOT NULL DEFAULT 1
+);
+
+CREATE INDEX IF NOT EXISTS idx_household_members_user_id ON household_members(user_id);
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_images_user_id;
+DROP TABLE IF EXISTS images;
\ No newline at end of file
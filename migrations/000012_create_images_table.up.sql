@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS images (
+    key text PRIMARY KEY,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    user_id bigint NOT NULL REFERENCES users ON DELETE CASCADE,
+    content_type text NOT NULL,
+    data bytea NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_images_user_id ON images(user_id);
\ No newline at end of file
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_recipes_publish_at;
+ALTER TABLE recipes DROP COLUMN IF EXISTS publish_at;
\ No newline at end of file
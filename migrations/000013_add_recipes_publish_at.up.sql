@@ -0,0 +1,3 @@
+ALTER TABLE recipes ADD COLUMN IF NOT EXISTS publish_at timestamp(0) with time zone;
+
+CREATE INDEX IF NOT EXISTS idx_recipes_publish_at ON recipes(publish_at) WHERE publish_at IS NOT NULL AND public = FALSE;
\ No newline at end of file
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_recipe_collaborators_user_id;
+DROP TABLE IF EXISTS recipe_collaborators;
\ No newline at end of file
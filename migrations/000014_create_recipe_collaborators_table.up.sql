@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS recipe_collaborators (
+    recipe_id bigint NOT NULL REFERENCES recipes ON DELETE CASCADE,
+    user_id bigint NOT NULL REFERENCES users ON DELETE CASCADE,
+    access text NOT NULL CHECK (access IN ('read', 'edit')),
+    granted_by bigint REFERENCES users ON DELETE SET NULL,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (recipe_id, user_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_recipe_collaborators_user_id ON recipe_collaborators(user_id);
\ No newline at end of file
@@ -0,0 +1 @@
+DROP TABLE IF EXISTS user_limits;
\ No newline at end of file
@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS user_limits (
+    user_id bigint PRIMARY KEY REFERENCES users ON DELETE CASCADE,
+    rps double precision CHECK (rps > 0),
+    burst integer CHECK (burst > 0),
+    max_recipes bigint CHECK (max_recipes >= 0),
+    max_image_bytes bigint CHECK (max_image_bytes >= 0),
+    version integer NOT NULL DEFAULT 1
+);
\ No newline at end of file
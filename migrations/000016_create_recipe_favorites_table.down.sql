@@ -0,0 +1,4 @@
+CREATE INDEX IF NOT EXISTS idx_recipes_user_id ON recipes(user_id);
+DROP INDEX IF EXISTS idx_recipes_user_id_name;
+DROP INDEX IF EXISTS idx_recipe_favorites_recipe_id;
+DROP TABLE IF EXISTS recipe_favorites;
\ No newline at end of file
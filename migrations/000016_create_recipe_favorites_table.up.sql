@@ -0,0 +1,14 @@
+CREATE TABLE IF NOT EXISTS recipe_favorites (
+    user_id bigint NOT NULL REFERENCES users ON DELETE CASCADE,
+    recipe_id bigint NOT NULL REFERENCES recipes ON DELETE CASCADE,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (user_id, recipe_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_recipe_favorites_recipe_id ON recipe_favorites(recipe_id);
+
+-- scope=mine searches narrow recipes by creator before anything else, so index the
+-- creator together with the name used for sorting. This supersedes the single-column
+-- index from 000003.
+CREATE INDEX IF NOT EXISTS idx_recipes_user_id_name ON recipes(user_id, name);
+DROP INDEX IF EXISTS idx_recipes_user_id;
\ No newline at end of file
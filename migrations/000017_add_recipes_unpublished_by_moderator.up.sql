@@ -0,0 +1 @@
+ALTER TABLE recipes ADD COLUMN IF NOT EXISTS unpublished_by_moderator boolean NOT NULL DEFAULT FALSE;
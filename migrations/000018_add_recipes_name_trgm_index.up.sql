@@ -0,0 +1,5 @@
+-- Name searches match anywhere in the name (ILIKE '%name%'), which a btree index
+-- can't serve, so index the name by trigrams instead.
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+CREATE INDEX IF NOT EXISTS idx_recipes_name_trgm ON recipes USING gin (name gin_trgm_ops);
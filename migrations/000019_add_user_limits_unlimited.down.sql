@@ -0,0 +1,5 @@
+UPDATE user_limits SET max_recipes = 0 WHERE unlimited_recipes;
+UPDATE user_limits SET max_image_bytes = 0 WHERE unlimited_image_bytes;
+
+ALTER TABLE user_limits DROP COLUMN IF EXISTS unlimited_image_bytes;
+ALTER TABLE user_limits DROP COLUMN IF EXISTS unlimited_recipes;
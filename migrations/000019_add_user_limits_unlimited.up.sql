@@ -0,0 +1,7 @@
+ALTER TABLE user_limits ADD COLUMN IF NOT EXISTS unlimited_recipes boolean NOT NULL DEFAULT FALSE;
+ALTER TABLE user_limits ADD COLUMN IF NOT EXISTS unlimited_image_bytes boolean NOT NULL DEFAULT FALSE;
+
+-- A quota override of 0 used to mean no limit. It now blocks the user instead, so
+-- convert the existing ones to the explicit flags.
+UPDATE user_limits SET unlimited_recipes = TRUE, max_recipes = NULL WHERE max_recipes = 0;
+UPDATE user_limits SET unlimited_image_bytes = TRUE, max_image_bytes = NULL WHERE max_image_bytes = 0;